@@ -0,0 +1,137 @@
+package HTTPLogger
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// AccessFormat is how AccessLog writes a line for each request
+type AccessFormat int
+
+const (
+	AccessStructured AccessFormat = iota // A line in the logger's own format, carrying the request's fields
+	AccessCommon                         // Apache Common Log Format
+	AccessCombined                       // Apache Combined Log Format, Common plus referer and user agent
+	AccessOff                            // No access log
+)
+
+// ParseAccessFormat returns the AccessFormat named by s: structured, common, combined, or off
+func ParseAccessFormat(s string) (AccessFormat, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "structured":
+		return AccessStructured, nil
+	case "common":
+		return AccessCommon, nil
+	case "combined":
+		return AccessCombined, nil
+	case "off", "none":
+		return AccessOff, nil
+	}
+	return AccessStructured, fmt.Errorf("Unknown access log format %q, must be structured, common, combined, or off", s)
+}
+
+// ResponseRecorder wraps a ResponseWriter, remembering the status and number of body bytes written
+type ResponseRecorder struct {
+	http.ResponseWriter
+	Status int   // Status written, 200 if the handler never called WriteHeader
+	Bytes  int64 // Bytes written in the body
+}
+
+// WriteHeader records the status and writes it
+func (r *ResponseRecorder) WriteHeader(status int) {
+	r.Status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Write counts the bytes written
+func (r *ResponseRecorder) Write(b []byte) (int, error) {
+	n, err := r.ResponseWriter.Write(b)
+	r.Bytes += int64(n)
+	return n, err
+}
+
+// Unwrap allows http.ResponseController to reach the underlying ResponseWriter
+func (r *ResponseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// AccessLog is middleware that logs every request once it has been served, with its status,
+// body size, and how long it took, so that handlers do not need to log HTTP outcomes themselves.
+// Lines are written at INFO. Place it inside RequestID so structured lines carry the request's fields.
+func AccessLog(l *Logger, format AccessFormat) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if format == AccessOff {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			recorder := &ResponseRecorder{ResponseWriter: w, Status: http.StatusOK}
+
+			next.ServeHTTP(recorder, r)
+
+			l.access(r, format, recorder.Status, recorder.Bytes, start)
+		})
+	}
+}
+
+// access writes an access log line for a request
+func (l *Logger) access(r *http.Request, format AccessFormat, status int, bytes int64, start time.Time) {
+	if !l.enabled(LOG_INFO) {
+		return
+	}
+
+	if format == AccessStructured {
+		l.WithRequest(r).output(slog.LevelInfo, fmt.Sprintf("%v %v %v", r.Method, r.RequestURI, status), []slog.Attr{
+			slog.Int("status", status),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.Int64("bytes", bytes),
+			slog.String("remote_addr", r.RemoteAddr),
+		})
+		return
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	user := "-"
+	if request := requestFieldsFrom(r.Context()); request != nil {
+		if name := request.value("user"); name != "" {
+			user = name
+		}
+	}
+
+	size := "-"
+	if bytes > 0 {
+		size = fmt.Sprint(bytes)
+	}
+
+	line := fmt.Sprintf("%v - %v [%v] %q %v %v", host, user, start.Format("02/Jan/2006:15:04:05 -0700"),
+		r.Method+" "+r.RequestURI+" "+r.Proto, status, size)
+	if format == AccessCombined {
+		line += fmt.Sprintf(" %q %q", orDash(r.Referer()), orDash(r.UserAgent()))
+	}
+
+	// Loggers writing to a slog.Handler have no writer of their own, so the line becomes the message
+	if l.out == nil {
+		l.output(slog.LevelInfo, line, nil)
+		return
+	}
+	if _, err := fmt.Fprintln(l.out, line); err != nil {
+		fmt.Printf("LOGGING ERROR: %v\n", err)
+	}
+}
+
+// orDash returns s, or "-" if it is empty, as Apache writes missing values
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,284 @@
+package HTTPLogger
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat is the timestamp in the name of a rotated file, e.g. appened-2006-01-02T15-04-05.000.log
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// FileConfig configures a File
+type FileConfig struct {
+	Path        string        // File lines are written to, created if it does not exist
+	MaxSize     int64         // Rotate once the file would grow past this many bytes, 0 for no limit
+	RotateEvery time.Duration // Rotate once the file has been written to for this long, 0 for no limit
+	MaxBackups  int           // Number of rotated files to keep, 0 keeps them all
+	MaxAge      time.Duration // Delete rotated files last written to longer ago than this, 0 keeps them all
+	Compress    bool          // Gzip rotated files
+}
+
+// File is an io.Writer that appends to a log file, rotating it by size and age.
+// Rotated files are renamed with the time they were rotated, optionally compressed, and removed
+// once there are more than MaxBackups of them or they were last written to longer ago than MaxAge.
+type File struct {
+	config  FileConfig
+	mu      *sync.Mutex
+	file    *os.File // Nil if rotating failed part way, until the next Write opens it again
+	closed  bool
+	size    int64
+	opened  time.Time
+	tidy    *sync.WaitGroup // Compression and removal of rotated files that is still running
+	tidying *sync.Mutex     // Held while rotated files are compressed and removed, one rotation at a time
+}
+
+// OpenFile opens a log file for appending
+func OpenFile(config FileConfig) (*File, error) {
+	if err := os.MkdirAll(filepath.Dir(config.Path), 0755); err != nil {
+		return nil, err
+	}
+
+	f := &File{config: config, mu: &sync.Mutex{}, tidy: &sync.WaitGroup{}, tidying: &sync.Mutex{}}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// Write appends p to the file, rotating it first if it is too big or too old. If rotating fails,
+// p is still written to the file at the configured path, and rotating is tried again next time.
+func (f *File) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return 0, os.ErrClosed
+	}
+
+	tooBig := f.config.MaxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.config.MaxSize
+	tooOld := f.config.RotateEvery > 0 && time.Since(f.opened) >= f.config.RotateEvery
+	if tooBig || tooOld {
+		if err := f.rotate(); err != nil {
+			fmt.Printf("LOGGING ERROR: Rotating %v: %v\n", f.config.Path, err)
+		}
+	}
+	if f.file == nil {
+		if err := f.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Rotate starts a new file, regardless of the size or age of the current one
+func (f *File) Rotate() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return os.ErrClosed
+	}
+	return f.rotate()
+}
+
+// Close closes the file, waiting for rotated files to finish compressing
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return nil
+	}
+
+	var err error
+	if f.file != nil {
+		err = f.file.Close()
+	}
+	f.file, f.closed = nil, true
+	f.tidy.Wait()
+	return err
+}
+
+// open opens the file at the configured path for appending
+func (f *File) open() error {
+	file, err := os.OpenFile(f.config.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	f.file, f.size, f.opened = file, info.Size(), time.Now()
+	return nil
+}
+
+// rotate renames the current file to a backup and opens a new one. f.mu must be held.
+// If it fails, f.file is left nil for the next Write to open again.
+func (f *File) rotate() error {
+	if f.file != nil {
+		err := f.file.Close()
+		f.file = nil
+		if err != nil {
+			return err
+		}
+	}
+
+	backup := f.backupPath(time.Now())
+	if err := os.Rename(f.config.Path, backup); err != nil {
+		return err
+	}
+
+	if err := f.open(); err != nil {
+		return err
+	}
+
+	f.tidy.Add(1)
+	go func() {
+		defer f.tidy.Done()
+
+		// Otherwise pruning after one rotation could remove a backup another is still compressing
+		f.tidying.Lock()
+		defer f.tidying.Unlock()
+
+		if f.config.Compress {
+			if err := compress(backup); err != nil {
+				fmt.Printf("LOGGING ERROR: %v\n", err)
+			}
+		}
+		if err := f.prune(); err != nil {
+			fmt.Printf("LOGGING ERROR: %v\n", err)
+		}
+	}()
+
+	return nil
+}
+
+// backupPath returns the path to rotate the file to at now. If the file was already rotated at that
+// millisecond, a later one that is free is used, so that backups are not overwritten.
+func (f *File) backupPath(now time.Time) string {
+	ext := filepath.Ext(f.config.Path)
+	for {
+		backup := strings.TrimSuffix(f.config.Path, ext) + "-" + now.Format(backupTimeFormat) + ext
+		if _, err := os.Lstat(backup); err != nil {
+			if _, err := os.Lstat(backup + ".gz"); err != nil {
+				return backup
+			}
+		}
+		now = now.Add(time.Millisecond)
+	}
+}
+
+// prune removes rotated files beyond MaxBackups or last written to longer ago than MaxAge
+func (f *File) prune() error {
+	if f.config.MaxBackups <= 0 && f.config.MaxAge <= 0 {
+		return nil
+	}
+
+	ext := filepath.Ext(f.config.Path)
+	prefix := strings.TrimSuffix(filepath.Base(f.config.Path), ext) + "-"
+
+	entries, err := os.ReadDir(filepath.Dir(f.config.Path))
+	if err != nil {
+		return err
+	}
+
+	type backup struct {
+		path     string
+		rotated  time.Time
+		modified time.Time
+	}
+	backups := []backup{}
+	for _, entry := range entries {
+		name := entry.Name()
+		stamp := strings.TrimSuffix(strings.TrimSuffix(name, ".gz"), ext)
+		if entry.IsDir() || !strings.HasPrefix(stamp, prefix) {
+			continue
+		}
+
+		rotated, err := time.ParseInLocation(backupTimeFormat, strings.TrimPrefix(stamp, prefix), time.Local)
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		backups = append(backups, backup{filepath.Join(filepath.Dir(f.config.Path), name), rotated, info.ModTime()})
+	}
+
+	// Newest first
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].rotated.After(backups[j].rotated)
+	})
+
+	for i, b := range backups {
+		tooMany := f.config.MaxBackups > 0 && i >= f.config.MaxBackups
+		tooOld := f.config.MaxAge > 0 && time.Since(b.modified) > f.config.MaxAge
+		if tooMany || tooOld {
+			if err := os.Remove(b.path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// compress gzips a file, replacing it with path.gz modified at the same time. A file that has
+// already been removed is left alone.
+func compress(path string) error {
+	src, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(dst)
+	if _, err := io.Copy(gz, src); err != nil {
+		dst.Close()
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		dst.Close()
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	if err := os.Chtimes(path+".gz", info.ModTime(), info.ModTime()); err != nil {
+		return err
+	}
+
+	return os.Remove(path)
+}
@@ -0,0 +1,225 @@
+package HTTPLogger
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testFile opens a log file in a new directory with config, closing it when the test ends
+func testFile(t *testing.T, config FileConfig) (*File, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	config.Path = filepath.Join(dir, "app.log")
+	f, err := OpenFile(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	return f, dir
+}
+
+// write writes each line to f, failing the test if one can't be
+func write(t *testing.T, f *File, lines ...string) {
+	t.Helper()
+
+	for _, line := range lines {
+		if _, err := f.Write([]byte(line + "\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// rotate rotates f, waiting first so that backups are not named for the same millisecond
+func rotate(t *testing.T, f *File) {
+	t.Helper()
+
+	time.Sleep(2 * time.Millisecond)
+	if err := f.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// backups returns the names of the rotated files in dir, oldest first
+func backups(t *testing.T, dir string) []string {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, entry := range entries {
+		if entry.Name() != "app.log" {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// readLog returns the contents of a log file, decompressing it if it is gzipped
+func readLog(t *testing.T, path string) string {
+	t.Helper()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	if strings.HasSuffix(path, ".gz") {
+		if r, err = gzip.NewReader(file); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestFileRotatesBySize(t *testing.T) {
+	f, dir := testFile(t, FileConfig{MaxSize: 10})
+
+	// Each line fits on its own, but not with the one before it
+	write(t, f, "first", "second", "third")
+	f.Close()
+
+	names := backups(t, dir)
+	if len(names) != 2 {
+		t.Fatalf("Got backups %q, want 2", names)
+	}
+	for i, want := range []string{"first\n", "second\n"} {
+		if got := readLog(t, filepath.Join(dir, names[i])); got != want {
+			t.Errorf("Backup %v is %q, want %q", names[i], got, want)
+		}
+	}
+	if got := readLog(t, filepath.Join(dir, "app.log")); got != "third\n" {
+		t.Errorf("Log file is %q, want %q", got, "third\n")
+	}
+}
+
+func TestFileRotatesByAge(t *testing.T) {
+	f, dir := testFile(t, FileConfig{RotateEvery: 20 * time.Millisecond})
+
+	write(t, f, "first")
+	time.Sleep(30 * time.Millisecond)
+	write(t, f, "second")
+	f.Close()
+
+	if names := backups(t, dir); len(names) != 1 {
+		t.Errorf("Got backups %q, want 1", names)
+	}
+	if got := readLog(t, filepath.Join(dir, "app.log")); got != "second\n" {
+		t.Errorf("Log file is %q, want %q", got, "second\n")
+	}
+}
+
+func TestFileKeepsMaxBackups(t *testing.T) {
+	f, dir := testFile(t, FileConfig{MaxBackups: 2, Compress: true})
+
+	for _, line := range []string{"1", "2", "3", "4", "5"} {
+		write(t, f, line)
+		rotate(t, f)
+	}
+	f.Close()
+
+	// Only the newest are kept, each compressed, with none lost or left uncompressed by pruning
+	// while another rotation's backup was being compressed
+	names := backups(t, dir)
+	if len(names) != 2 {
+		t.Fatalf("Got backups %q, want 2", names)
+	}
+	for i, want := range []string{"4\n", "5\n"} {
+		if !strings.HasSuffix(names[i], ".log.gz") {
+			t.Errorf("Backup %v is not compressed", names[i])
+			continue
+		}
+		if got := readLog(t, filepath.Join(dir, names[i])); got != want {
+			t.Errorf("Backup %v is %q, want %q", names[i], got, want)
+		}
+	}
+}
+
+func TestFileRemovesOldBackups(t *testing.T) {
+	f, dir := testFile(t, FileConfig{MaxAge: time.Hour})
+
+	// Age is when a backup was last written to, not the time in its name
+	now, longAgo := time.Now(), time.Now().Add(-48*time.Hour)
+	recent := "app-" + longAgo.Format(backupTimeFormat) + ".log"
+	stale := "app-" + now.Add(-time.Minute).Format(backupTimeFormat) + ".log"
+	unrelated := "other-" + longAgo.Format(backupTimeFormat) + ".log"
+	for name, modified := range map[string]time.Time{recent: now, stale: longAgo, unrelated: longAgo} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("old\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modified, modified); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write(t, f, "new")
+	rotate(t, f)
+	f.Close()
+
+	names := backups(t, dir)
+	if len(names) != 3 || names[0] != recent || names[2] != unrelated {
+		t.Errorf("Got backups %q, want %v, the new one and %v", names, recent, unrelated)
+	}
+}
+
+func TestFileKeepsLoggingAfterFailedRotation(t *testing.T) {
+	f, dir := testFile(t, FileConfig{})
+	write(t, f, "first")
+
+	// Moving the directory away makes rotating, and then opening the file again, fail
+	moved := dir + "-moved"
+	if err := os.Rename(dir, moved); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(moved) })
+	if err := f.Rotate(); err == nil {
+		t.Fatal("Rotating without a directory to rotate in succeeded")
+	}
+	if _, err := f.Write([]byte("second\n")); err == nil {
+		t.Fatal("Writing without a directory to write in succeeded")
+	}
+
+	// Once the directory is back, writing opens the file again
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	write(t, f, "third", "fourth")
+	f.Close()
+
+	if got := readLog(t, filepath.Join(dir, "app.log")); got != "third\nfourth\n" {
+		t.Errorf("Log file is %q, want %q", got, "third\nfourth\n")
+	}
+}
+
+func TestFileClose(t *testing.T) {
+	f, _ := testFile(t, FileConfig{})
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Errorf("Closing twice got error %v", err)
+	}
+	if _, err := f.Write([]byte("line\n")); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Writing after closing got error %v, want %v", err, os.ErrClosed)
+	}
+	if err := f.Rotate(); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Rotating after closing got error %v, want %v", err, os.ErrClosed)
+	}
+}
@@ -0,0 +1,83 @@
+package HTTPLogger
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Handler returns a slog.Handler that writes through the logger, so that it can back a slog.Logger:
+//
+//	slog.SetDefault(slog.New(logger.Handler()))
+//
+// Records are filtered by the logger's level. Records logged with the context of a request that
+// passed through RequestID carry the request's fields, e.g. slog.InfoContext(r.Context(), "msg").
+func (l *Logger) Handler() slog.Handler {
+	return &handler{logger: l}
+}
+
+// handler adapts a Logger to slog.Handler
+type handler struct {
+	logger *Logger
+	attrs  []slog.Attr // Added with WithAttrs, with keys already qualified by their groups
+	group  string      // Prefix for keys, from WithGroup, e.g. "db."
+}
+
+func (h *handler) Enabled(_ context.Context, level slog.Level) bool {
+	switch {
+	case level >= slog.LevelError:
+		return h.logger.enabled(LOG_ERRORS)
+	case level >= slog.LevelWarn:
+		return h.logger.enabled(LOG_WARNINGS)
+	case level >= slog.LevelInfo:
+		return h.logger.enabled(LOG_INFO)
+	}
+	return h.logger.enabled(LOG_DEBUG)
+}
+
+func (h *handler) Handle(ctx context.Context, record slog.Record) error {
+	fields := append([]slog.Attr{}, h.attrs...)
+	record.Attrs(func(attr slog.Attr) bool {
+		fields = append(fields, h.qualify(attr))
+		return true
+	})
+
+	l := h.logger
+	if request := requestFieldsFrom(ctx); request != nil {
+		child := *l
+		child.request = request
+		l = &child
+	}
+
+	// As with Logger.Error, only errors say where they were logged from
+	pc := record.PC
+	if record.Level < slog.LevelError {
+		pc = 0
+	}
+
+	return l.write(record.Level, record.Message, fields, record.Time, pc)
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	child := *h
+	child.attrs = append([]slog.Attr{}, h.attrs...)
+	for _, attr := range attrs {
+		child.attrs = append(child.attrs, h.qualify(attr))
+	}
+	return &child
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+
+	child := *h
+	child.group = h.group + name + "."
+	return &child
+}
+
+// qualify prefixes an attribute's key with the handler's groups
+func (h *handler) qualify(attr slog.Attr) slog.Attr {
+	attr.Key = h.group + attr.Key
+	return attr
+}
@@ -1,10 +1,16 @@
 package HTTPLogger
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log"
+	"log/slog"
 	"net/http"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
 )
 
 const (
@@ -12,80 +18,329 @@ const (
 	LOG_INFO
 	LOG_WARNINGS
 	LOG_DEBUG
-	LOG_NONE
-	LOG_ALL = LOG_ERRORS | LOG_INFO | LOG_WARNINGS | LOG_DEBUG
+	LOG_NONE // Overrides every other flag, so that nothing is logged
+	LOG_ALL  = LOG_ERRORS | LOG_INFO | LOG_WARNINGS | LOG_DEBUG
 )
 
+// levels are the named levels a logger can be set to. Each enables itself and every more severe stream.
+var levels = []struct {
+	name  string
+	flags int
+}{
+	{"debug", LOG_ALL},
+	{"info", LOG_ERRORS | LOG_WARNINGS | LOG_INFO},
+	{"warn", LOG_ERRORS | LOG_WARNINGS},
+	{"error", LOG_ERRORS},
+	{"none", LOG_NONE},
+}
+
+// ParseLevel returns the flags for the level named by s: debug, info, warn, error, or none
+func ParseLevel(s string) (int, error) {
+	name := strings.ToLower(strings.TrimSpace(s))
+	if name == "warning" {
+		name = "warn"
+	}
+	for _, level := range levels {
+		if level.name == name {
+			return level.flags, nil
+		}
+	}
+	return 0, fmt.Errorf("Unknown log level %q, must be debug, info, warn, error, or none", s)
+}
+
+// LevelName returns the name of the level flags enable, or "custom" if they are not a named level
+func LevelName(flags int) string {
+	if flags&LOG_NONE != 0 {
+		return "none"
+	}
+	for _, level := range levels {
+		if level.flags == flags {
+			return level.name
+		}
+	}
+	return "custom"
+}
+
+// Format is how log lines are written
+type Format int
+
+const (
+	FormatText   Format = iota // Pipe-delimited lines, e.g. `INFO | 2006/01/02 15:04:05 | msg | key=value`
+	FormatJSON                 // One JSON object per line
+	FormatLogfmt               // key=value pairs, one line per entry
+)
+
+// ParseFormat returns the Format named by s: text, json, or logfmt
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "text":
+		return FormatText, nil
+	case "json":
+		return FormatJSON, nil
+	case "logfmt":
+		return FormatLogfmt, nil
+	}
+	return FormatText, fmt.Errorf("Unknown log format %q, must be text, json, or logfmt", s)
+}
+
 // Logger is a generalized logger to save some boilerplate. It is made using the standard library.
+// Its Flags are shared with every logger derived from it with With, and can be changed while it is in
+// use with SetFlags or SetLevel.
 type Logger struct {
-	Flags int // Determines which log streams (err/info/debug/warn) are enabled
-	err   *log.Logger
-	info  *log.Logger
-	debug *log.Logger
-	warn  *log.Logger
+	*logLevel // Shared with loggers derived with With
+
+	err     *log.Logger
+	info    *log.Logger
+	debug   *log.Logger
+	warn    *log.Logger
+	out     io.Writer      // Where lines are written, used directly for Common and Combined access logs
+	handler slog.Handler   // Writes structured lines, nil when using FormatText
+	fields  []slog.Attr    // Fields written on every line
+	request *requestFields // Fields of the request the logger belongs to, if any
+}
+
+// logLevel is which log streams a logger and every logger derived from it write
+type logLevel struct {
+	// Flags determines which log streams (err/info/debug/warn) are enabled. Setting it directly is only safe
+	// before the logger is in use, SetFlags and SetLevel change it safely at any time.
+	Flags int
+
+	mu *sync.RWMutex // Guards Flags while SetFlags changes it
+}
+
+func newLogLevel(flags int) *logLevel {
+	return &logLevel{Flags: flags, mu: &sync.RWMutex{}}
+}
+
+// CurrentFlags returns which log streams are enabled, as Flags does while the level may be changing
+func (l *Logger) CurrentFlags() int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.Flags
+}
+
+// SetFlags changes which log streams are enabled
+func (l *Logger) SetFlags(flags int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.Flags = flags
+}
+
+// Level returns the name of the level the logger is set to
+func (l *Logger) Level() string {
+	return LevelName(l.CurrentFlags())
+}
+
+// SetLevel sets the logger to the level named by name, as parsed by ParseLevel
+func (l *Logger) SetLevel(name string) error {
+	flags, err := ParseLevel(name)
+	if err != nil {
+		return err
+	}
+	l.SetFlags(flags)
+	return nil
+}
+
+// enabled reports whether a stream is enabled
+func (l *Logger) enabled(flag int) bool {
+	flags := l.CurrentFlags()
+	return flags&LOG_NONE == 0 && flags&flag != 0
 }
 
 // Error logs application errors and includes file location/line number in output.
 // It is not meant for logging HTTP errors or other errors that arise from the client.
 func (l *Logger) Error(appErr error) {
-	if l.Flags&LOG_ERRORS != 0 {
-		err := l.err.Output(2, "| "+appErr.Error())
-		if err != nil {
-			fmt.Printf("LOGGING ERROR: %v\n", err)
-		}
+	if l.enabled(LOG_ERRORS) {
+		l.output(slog.LevelError, appErr.Error(), nil)
 	}
 }
 
 // Info logs with prefix INFO
 func (l *Logger) Info(msg string) {
-	if l.Flags&LOG_INFO != 0 {
-		err := l.info.Output(2, "| "+msg)
-		if err != nil {
-			fmt.Printf("LOGGING ERROR: %v\n", err)
-		}
+	if l.enabled(LOG_INFO) {
+		l.output(slog.LevelInfo, msg, nil)
 	}
 }
 
 // Debug logs with prefix DEBUG
 func (l *Logger) Debug(msg string) {
-	if l.Flags&LOG_DEBUG != 0 {
-		err := l.debug.Output(2, "| "+msg)
-		if err != nil {
-			fmt.Printf("LOGGING ERROR: %v\n", err)
-		}
+	if l.enabled(LOG_DEBUG) {
+		l.output(slog.LevelDebug, msg, nil)
 	}
 }
 
 // Warn logs with prefix WARNING
 func (l *Logger) Warn(msg string) {
-	if l.Flags&LOG_WARNINGS != 0 {
-		err := l.warn.Output(2, "| "+msg)
-		if err != nil {
-			fmt.Printf("LOGGING ERROR: %v\n", err)
-		}
+	if l.enabled(LOG_WARNINGS) {
+		l.output(slog.LevelWarn, msg, nil)
 	}
 }
 
-// InfoHTTP prints an INFO log message with the http method, the route, and the HTTP Status
+// InfoHTTP prints an INFO log message with the http method, the route, and the HTTP Status.
+// If the request passed through RequestID, the line also carries the request's fields and latency.
+// Prefer AccessLog, which logs every request without handlers having to call InfoHTTP.
 func (l *Logger) InfoHTTP(r *http.Request, status int) {
-	l.Info(fmt.Sprintf("%v %v %v", r.Method, r.RequestURI, status))
+	if !l.enabled(LOG_INFO) {
+		return
+	}
+
+	l = l.WithRequest(r)
+	fields := []slog.Attr{slog.Int("status", status)}
+	if l.request != nil {
+		fields = append(fields, slog.Float64("latency_ms", float64(time.Since(l.request.start).Microseconds())/1000))
+	}
+	l.output(slog.LevelInfo, fmt.Sprintf("%v %v %v", r.Method, r.RequestURI, status), fields)
 }
 
-// ApplicationError makes two calls: It first calls HTTPLogger with error 500, it then calls Error to log the error
+// ApplicationError logs an error that caused a request to fail, with the request's fields.
+// The request's status is left to AccessLog.
 func (l *Logger) ApplicationError(r *http.Request, err error) {
-	l.InfoHTTP(r, http.StatusInternalServerError)
-	l.Error(err)
+	if l.enabled(LOG_ERRORS) {
+		l.WithRequest(r).output(slog.LevelError, err.Error(), nil)
+	}
+}
+
+// With returns a logger that adds fields to every line it writes. args are alternating keys and
+// values, as with slog.Logger.With.
+func (l *Logger) With(args ...any) *Logger {
+	child := *l
+	child.fields = append(append([]slog.Attr{}, l.fields...), argsToAttrs(args)...)
+	return &child
+}
+
+// WithRequest returns a logger that writes the request's ID, and any fields added to it with
+// AddFields, on every line. Fields added to the request later are included too.
+func (l *Logger) WithRequest(r *http.Request) *Logger {
+	request := requestFieldsFrom(r.Context())
+	if request == nil {
+		return l
+	}
+
+	child := *l
+	child.request = request
+	return &child
+}
+
+// output writes a line at level, recording where errors were logged from.
+// It must be called directly by an exported method, so that the caller's location is correct.
+func (l *Logger) output(level slog.Level, msg string, extra []slog.Attr) {
+	const skip = 3 // runtime.Callers, output, and the exported method
+
+	var pc uintptr
+	if level >= slog.LevelError {
+		var pcs [1]uintptr
+		runtime.Callers(skip, pcs[:])
+		pc = pcs[0]
+	}
+
+	if err := l.write(level, msg, extra, time.Now(), pc); err != nil {
+		fmt.Printf("LOGGING ERROR: %v\n", err)
+	}
+}
+
+// write writes a line to the text logger for level, or to the structured handler if there is one.
+// The line carries the logger's fields, its request's fields, and then extra. A non-zero pc is the
+// location the line was logged from.
+func (l *Logger) write(level slog.Level, msg string, extra []slog.Attr, t time.Time, pc uintptr) error {
+	fields := append([]slog.Attr{}, l.fields...)
+	if l.request != nil {
+		fields = append(fields, l.request.attrs()...)
+	}
+	fields = append(fields, extra...)
+	msg = strings.TrimSuffix(msg, "\n")
+
+	if l.handler != nil {
+		record := slog.NewRecord(t, level, msg, pc)
+		record.AddAttrs(fields...)
+		return l.handler.Handle(context.Background(), record)
+	}
+
+	text := l.textLogger(level)
+	line := text.Prefix() + t.Format("2006/01/02 15:04:05 ")
+	if pc != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+		line += fmt.Sprintf("%v:%v: ", frame.File, frame.Line)
+	}
+	line += "| " + msg
+	if len(fields) > 0 {
+		pairs := make([]string, len(fields))
+		for i, field := range fields {
+			pairs[i] = field.String()
+		}
+		line += " | " + strings.Join(pairs, " ")
+	}
+
+	_, err := io.WriteString(text.Writer(), line+"\n")
+	return err
+}
+
+// textLogger returns the text logger lines at level are written to
+func (l *Logger) textLogger(level slog.Level) *log.Logger {
+	switch {
+	case level >= slog.LevelError:
+		return l.err
+	case level >= slog.LevelWarn:
+		return l.warn
+	case level >= slog.LevelInfo:
+		return l.info
+	}
+	return l.debug
+}
+
+// argsToAttrs converts alternating keys and values into attributes
+func argsToAttrs(args []any) []slog.Attr {
+	record := slog.Record{}
+	record.Add(args...)
+
+	attrs := make([]slog.Attr, 0, record.NumAttrs())
+	record.Attrs(func(attr slog.Attr) bool {
+		attrs = append(attrs, attr)
+		return true
+	})
+	return attrs
 }
 
 // New creates a new logger. The flags arguement specifies which kinds of data are written to.
 func New(out io.Writer, flags int) *Logger {
-	logger := &Logger{}
+	return NewWithFormat(out, flags, FormatText)
+}
 
-	logger.err = log.New(out, "ERROR | ", log.LstdFlags|log.Llongfile)
-	logger.info = log.New(out, "INFO | ", log.LstdFlags)
-	logger.debug = log.New(out, "DEBUG | ", log.LstdFlags)
-	logger.warn = log.New(out, "WARNING | ", log.LstdFlags)
-	logger.Flags = flags
+// NewWithFormat creates a new logger writing lines in the given format
+func NewWithFormat(out io.Writer, flags int, format Format) *Logger {
+	return NewWithSinks(Sinks{Default: out}, flags, format)
+}
+
+// NewWithSinks creates a new logger writing lines in the given format, sending each level to its own sink
+func NewWithSinks(sinks Sinks, flags int, format Format) *Logger {
+	logger := &Logger{logLevel: newLogLevel(flags)}
+
+	errOut, warnOut := sinks.writer(sinks.Error), sinks.writer(sinks.Warn)
+	infoOut, debugOut := sinks.writer(sinks.Info), sinks.writer(sinks.Debug)
+
+	logger.err = log.New(errOut, "ERROR | ", 0)
+	logger.info = log.New(infoOut, "INFO | ", 0)
+	logger.debug = log.New(debugOut, "DEBUG | ", 0)
+	logger.warn = log.New(warnOut, "WARNING | ", 0)
+	logger.out = infoOut
+
+	options := &slog.HandlerOptions{AddSource: true, Level: slog.LevelDebug}
+	newHandler := func(out io.Writer) slog.Handler {
+		if format == FormatJSON {
+			return slog.NewJSONHandler(out, options)
+		}
+		return slog.NewTextHandler(out, options)
+	}
+	if format != FormatText {
+		logger.handler = &levelHandler{newHandler(errOut), newHandler(warnOut), newHandler(infoOut), newHandler(debugOut)}
+	}
 
 	return logger
 }
+
+// NewWithHandler creates a new logger that writes every line to a slog.Handler, e.g. one shared
+// with the rest of an application. Lines are filtered by flags before they reach the handler.
+func NewWithHandler(h slog.Handler, flags int) *Logger {
+	return &Logger{logLevel: newLogLevel(flags), handler: h}
+}
@@ -0,0 +1,137 @@
+package HTTPLogger
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// logged reports which of debug, info, warn and error l writes a line for
+func logged(l *Logger, out *bytes.Buffer) []string {
+	streams := []string{}
+	for _, stream := range []struct {
+		name string
+		log  func(string)
+	}{
+		{"debug", l.Debug},
+		{"info", l.Info},
+		{"warn", l.Warn},
+		{"error", func(msg string) { l.Error(errors.New(msg)) }},
+	} {
+		out.Reset()
+		stream.log(stream.name + " line")
+		if strings.Contains(out.String(), stream.name+" line") {
+			streams = append(streams, stream.name)
+		}
+	}
+	return streams
+}
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		text  string
+		flags int
+		name  string // LevelName of flags
+		err   bool
+	}{
+		{"debug", LOG_ALL, "debug", false},
+		{"info", LOG_ERRORS | LOG_WARNINGS | LOG_INFO, "info", false},
+		{" WARN ", LOG_ERRORS | LOG_WARNINGS, "warn", false},
+		{"warning", LOG_ERRORS | LOG_WARNINGS, "warn", false},
+		{"error", LOG_ERRORS, "error", false},
+		{"none", LOG_NONE, "none", false},
+		{"verbose", 0, "", true},
+	}
+
+	for _, test := range tests {
+		flags, err := ParseLevel(test.text)
+		if (err != nil) != test.err || flags != test.flags {
+			t.Errorf("ParseLevel(%q) = %v, %v, want %v and error %v", test.text, flags, err, test.flags, test.err)
+		}
+		if !test.err && LevelName(flags) != test.name {
+			t.Errorf("LevelName(%v) = %q, want %q", flags, LevelName(flags), test.name)
+		}
+	}
+	if name := LevelName(LOG_INFO); name != "custom" {
+		t.Errorf("LevelName(LOG_INFO) = %q, want custom", name)
+	}
+}
+
+func TestLevels(t *testing.T) {
+	tests := []struct {
+		level  string
+		logged []string
+	}{
+		{"debug", []string{"debug", "info", "warn", "error"}},
+		{"info", []string{"info", "warn", "error"}},
+		{"warn", []string{"warn", "error"}},
+		{"error", []string{"error"}},
+		{"none", []string{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.level, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			l := New(out, LOG_ALL)
+			if err := l.SetLevel(test.level); err != nil {
+				t.Fatal(err)
+			}
+			if got := logged(l, out); strings.Join(got, ",") != strings.Join(test.logged, ",") {
+				t.Errorf("Logged %q, want %q", got, test.logged)
+			}
+			if l.Level() != test.level {
+				t.Errorf("Level is %q, want %q", l.Level(), test.level)
+			}
+		})
+	}
+}
+
+func TestFlagsAreOneLevel(t *testing.T) {
+	out := &bytes.Buffer{}
+	l := New(out, LOG_ALL)
+	derived := l.With("component", "test")
+
+	// Setting Flags directly still applies after the level was changed at runtime
+	l.SetLevel("error")
+	l.Flags = LOG_ERRORS | LOG_WARNINGS
+	if got := logged(l, out); strings.Join(got, ",") != "warn,error" {
+		t.Errorf("After setting Flags logged %q, want warn and error", got)
+	}
+	if l.CurrentFlags() != l.Flags {
+		t.Errorf("CurrentFlags is %v, Flags is %v", l.CurrentFlags(), l.Flags)
+	}
+
+	// Loggers derived before or after a change share the level
+	if derived.Flags != l.Flags || derived.Level() != "warn" {
+		t.Errorf("Derived logger has flags %v, want %v", derived.Flags, l.Flags)
+	}
+	derived.SetLevel("debug")
+	if l.Flags != LOG_ALL || l.With().Level() != "debug" {
+		t.Errorf("Setting the level of a derived logger left its parent at %v", l.Level())
+	}
+}
+
+func TestSetFlagsWhileLogging(t *testing.T) {
+	l := New(io.Discard, LOG_ALL)
+
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				l.With("j", j).Info("line")
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				l.SetLevel([]string{"debug", "error"}[j%2])
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,97 @@
+package HTTPLogger
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// RequestIDHeader is the header a request's ID is read from and written to
+const RequestIDHeader = "X-Request-ID"
+
+// validRequestID limits the IDs accepted from clients, so they are safe to log
+var validRequestID = regexp.MustCompile(`^[a-zA-Z0-9._\-]{1,128}$`)
+
+// requestFields is the logging state of a single request, shared by every logger for that request
+type requestFields struct {
+	id     string
+	start  time.Time
+	mu     *sync.Mutex
+	fields []slog.Attr
+}
+
+func (f *requestFields) attrs() []slog.Attr {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]slog.Attr{slog.String("request_id", f.id)}, f.fields...)
+}
+
+// value returns the most recent value of a field added to the request, or an empty string
+func (f *requestFields) value(key string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i := len(f.fields) - 1; i >= 0; i-- {
+		if f.fields[i].Key == key {
+			return f.fields[i].Value.String()
+		}
+	}
+	return ""
+}
+
+type requestFieldsKey struct{}
+
+func requestFieldsFrom(ctx context.Context) *requestFields {
+	fields, _ := ctx.Value(requestFieldsKey{}).(*requestFields)
+	return fields
+}
+
+// RequestID is middleware that gives every request an ID, taken from the X-Request-ID header if the
+// client sent a valid one. The ID is echoed in the response's X-Request-ID header, and is written on
+// every line logged through Logger.WithRequest, AccessLog, or ApplicationError for the request.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+
+		fields := &requestFields{id: id, start: time.Now(), mu: &sync.Mutex{}}
+		ctx := context.WithValue(r.Context(), requestFieldsKey{}, fields)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RequestIDFromContext returns the ID RequestID gave a request, or an empty string if it has none
+func RequestIDFromContext(ctx context.Context) string {
+	if fields := requestFieldsFrom(ctx); fields != nil {
+		return fields.id
+	}
+	return ""
+}
+
+// AddFields adds fields, given as alternating keys and values, to every line logged for a request
+// from now on. It does nothing if the request did not pass through RequestID.
+func AddFields(r *http.Request, args ...any) {
+	fields := requestFieldsFrom(r.Context())
+	if fields == nil {
+		return
+	}
+
+	fields.mu.Lock()
+	defer fields.mu.Unlock()
+	fields.fields = append(fields.fields, argsToAttrs(args)...)
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,122 @@
+package HTTPLogger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+)
+
+// Sinks are where each level's lines are written. Levels without a sink of their own are written to Default.
+type Sinks struct {
+	Default io.Writer
+	Error   io.Writer
+	Warn    io.Writer
+	Info    io.Writer // Also where access log lines are written
+	Debug   io.Writer
+}
+
+// writer returns the sink for a level, falling back to Default
+func (s Sinks) writer(sink io.Writer) io.Writer {
+	if sink == nil {
+		return s.Default
+	}
+	return sink
+}
+
+// Close closes every sink that is a File
+func (s Sinks) Close() error {
+	closed := map[*File]bool{}
+	var errs []error
+	for _, sink := range []io.Writer{s.Default, s.Error, s.Warn, s.Info, s.Debug} {
+		if f, ok := sink.(*File); ok && !closed[f] {
+			closed[f] = true
+			errs = append(errs, f.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// FileOptions configures log files from a config file or the environment
+type FileOptions struct {
+	Path        string `json:"path"`        // File every level is written to, if empty lines go to the default writer
+	ErrorPath   string `json:"errorPath"`   // File errors are written to instead, if set
+	MaxSizeMB   int    `json:"maxSizeMB"`   // Rotate files once they reach this many megabytes
+	RotateEvery string `json:"rotateEvery"` // Rotate files this often, e.g. "24h"
+	MaxBackups  int    `json:"maxBackups"`  // Number of rotated files to keep
+	MaxAge      string `json:"maxAge"`      // Delete rotated files older than this, e.g. "720h"
+	Compress    bool   `json:"compress"`    // Gzip rotated files
+}
+
+// Open opens the configured files, returning sinks that write to them. Anything not written to a file is written to out.
+func (o FileOptions) Open(out io.Writer) (Sinks, error) {
+	sinks := Sinks{Default: out}
+
+	config := FileConfig{
+		MaxSize:    int64(o.MaxSizeMB) * 1024 * 1024,
+		MaxBackups: o.MaxBackups,
+		Compress:   o.Compress,
+	}
+	var err error
+	if o.RotateEvery != "" {
+		if config.RotateEvery, err = time.ParseDuration(o.RotateEvery); err != nil {
+			return sinks, fmt.Errorf("Invalid log rotation interval: %w", err)
+		}
+	}
+	if o.MaxAge != "" {
+		if config.MaxAge, err = time.ParseDuration(o.MaxAge); err != nil {
+			return sinks, fmt.Errorf("Invalid log retention: %w", err)
+		}
+	}
+
+	if o.Path != "" {
+		config.Path = o.Path
+		if sinks.Default, err = OpenFile(config); err != nil {
+			return sinks, err
+		}
+	}
+	if o.ErrorPath != "" {
+		config.Path = o.ErrorPath
+		if sinks.Error, err = OpenFile(config); err != nil {
+			sinks.Close()
+			return sinks, err
+		}
+	}
+
+	return sinks, nil
+}
+
+// levelHandler sends records to a different handler for each level
+type levelHandler struct {
+	err, warn, info, debug slog.Handler
+}
+
+func (h *levelHandler) handler(level slog.Level) slog.Handler {
+	switch {
+	case level >= slog.LevelError:
+		return h.err
+	case level >= slog.LevelWarn:
+		return h.warn
+	case level >= slog.LevelInfo:
+		return h.info
+	}
+	return h.debug
+}
+
+func (h *levelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler(level).Enabled(ctx, level)
+}
+
+func (h *levelHandler) Handle(ctx context.Context, record slog.Record) error {
+	return h.handler(record.Level).Handle(ctx, record)
+}
+
+func (h *levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &levelHandler{h.err.WithAttrs(attrs), h.warn.WithAttrs(attrs), h.info.WithAttrs(attrs), h.debug.WithAttrs(attrs)}
+}
+
+func (h *levelHandler) WithGroup(name string) slog.Handler {
+	return &levelHandler{h.err.WithGroup(name), h.warn.WithGroup(name), h.info.WithGroup(name), h.debug.WithGroup(name)}
+}
@@ -0,0 +1,314 @@
+// sdkgen generates the typed v2 go-sdk from the OpenAPI document in the api package.
+// It is run with go generate from clients/go-sdk, so that the SDK stays in sync with what the server validates.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/appened/api"
+)
+
+func main() {
+	out := flag.String("out", "v2_gen.go", "file to write the generated SDK to")
+	pkg := flag.String("package", "appendedGo", "package name of the generated file")
+	flag.Parse()
+
+	doc, err := api.Load()
+	if err != nil {
+		log.Fatalf("Error loading OpenAPI document:\n%v", err)
+	}
+
+	src, err := generate(doc, *pkg)
+	if err != nil {
+		log.Fatalf("Error generating SDK:\n%v", err)
+	}
+
+	if err = os.WriteFile(*out, src, 0644); err != nil {
+		log.Fatalf("Error writing %v:\n%v", *out, err)
+	}
+}
+
+// generate returns the formatted Go source for every schema and operation in doc
+func generate(doc *api.Document, pkg string) ([]byte, error) {
+	g := &generator{doc: doc}
+
+	g.printf("// Code generated by api/sdkgen from api/openapi.json. DO NOT EDIT.\n\n")
+	g.printf("package %v\n\n", pkg)
+	g.printf("import (\n\"fmt\"\n\"net/url\"\n)\n\n")
+
+	// Types for every schema
+	names := []string{}
+	for name := range doc.Components.Schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := g.schemaType(name, doc.Components.Schemas[name]); err != nil {
+			return nil, fmt.Errorf("schema %v: %v", name, err)
+		}
+	}
+
+	// A method for every operation
+	paths := []string{}
+	for path := range doc.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		for _, method := range []string{"get", "post", "put", "patch", "delete"} {
+			op, ok := doc.Paths[path][method]
+			if !ok {
+				continue
+			}
+			if err := g.operation(path, method, op); err != nil {
+				return nil, fmt.Errorf("%v %v: %v", strings.ToUpper(method), path, err)
+			}
+		}
+	}
+
+	return format.Source(g.buf.Bytes())
+}
+
+type generator struct {
+	doc *api.Document
+	buf bytes.Buffer
+}
+
+func (g *generator) printf(format string, a ...interface{}) {
+	fmt.Fprintf(&g.buf, format, a...)
+}
+
+// schemaType writes a struct for an object schema
+func (g *generator) schemaType(name string, s *api.Schema) error {
+	if s.Type != "object" {
+		return fmt.Errorf("Only object schemas can be named, got %v", s.Type)
+	}
+
+	required := map[string]bool{}
+	for _, r := range s.Required {
+		required[r] = true
+	}
+
+	properties := []string{}
+	for property := range s.Properties {
+		properties = append(properties, property)
+	}
+	sort.Strings(properties)
+
+	g.comment(name, s.Description, fmt.Sprintf("is the %v schema", name))
+	g.printf("type %v struct {\n", name)
+	for _, property := range properties {
+		goType, err := g.goType(s.Properties[property])
+		if err != nil {
+			return fmt.Errorf("property %v: %v", property, err)
+		}
+
+		tag := property
+		if !required[property] {
+			tag += ",omitempty"
+			// Optional numbers and booleans are pointers so that their zero value can be sent
+			if goType == "int" || goType == "int64" || goType == "float64" || goType == "bool" {
+				goType = "*" + goType
+			}
+		}
+		g.printf("%v %v `json:\"%v\"`\n", exported(property), goType, tag)
+	}
+	g.printf("}\n\n")
+
+	return nil
+}
+
+// goType returns the Go type used for a schema
+func (g *generator) goType(s *api.Schema) (string, error) {
+	if s.Ref != "" {
+		resolved, err := g.doc.Schema(s)
+		if err != nil {
+			return "", err
+		}
+		if resolved.Type != "object" {
+			return g.goType(resolved)
+		}
+		return s.Ref[strings.LastIndex(s.Ref, "/")+1:], nil
+	}
+
+	switch s.Type {
+	case "string":
+		return "string", nil
+	case "boolean":
+		return "bool", nil
+	case "number":
+		return "float64", nil
+	case "integer":
+		if s.Format == "int64" {
+			return "int64", nil
+		}
+		return "int", nil
+	case "array":
+		item, err := g.goType(s.Items)
+		if err != nil {
+			return "", err
+		}
+		return "[]" + item, nil
+	}
+
+	return "", fmt.Errorf("Unsupported inline schema of type %q", s.Type)
+}
+
+var pathParamPattern = regexp.MustCompile(`\{([a-zA-Z]+)\}`)
+
+// operation writes a V2Client method, and a parameters struct if the operation takes query parameters
+func (g *generator) operation(path string, method string, op api.Operation) error {
+	if op.OperationID == "" {
+		return fmt.Errorf("Missing operationId")
+	}
+	name := exported(op.OperationID)
+
+	args := []string{}
+	pathTypes := map[string]string{}
+	queryParams := []*api.Parameter{}
+	for _, param := range op.Parameters {
+		goType, err := g.goType(param.Schema)
+		if err != nil {
+			return fmt.Errorf("parameter %v: %v", param.Name, err)
+		}
+		if param.In == "path" {
+			pathTypes[param.Name] = goType
+			args = append(args, fmt.Sprintf("%v %v", param.Name, goType))
+		} else {
+			queryParams = append(queryParams, param)
+		}
+	}
+
+	if len(queryParams) > 0 {
+		g.comment(name+"Params", "", fmt.Sprintf("holds the optional query parameters of %v", name))
+		g.printf("type %vParams struct {\n", name)
+		for _, param := range queryParams {
+			goType, _ := g.goType(param.Schema)
+			if param.Description != "" {
+				g.printf("// %v\n", param.Description)
+			}
+			g.printf("%v *%v\n", exported(param.Name), goType)
+		}
+		g.printf("}\n\n")
+		args = append(args, fmt.Sprintf("params *%vParams", name))
+	}
+
+	body := "nil"
+	if op.RequestBody != nil {
+		goType, err := g.goType(op.RequestBody.Content["application/json"].Schema)
+		if err != nil {
+			return fmt.Errorf("request body: %v", err)
+		}
+		args = append(args, "body "+goType)
+		body = "body"
+	}
+
+	// The result is the JSON body of the first successful response, if it has one
+	result := ""
+	statuses := []string{}
+	for status := range op.Responses {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+	for _, status := range statuses {
+		if !strings.HasPrefix(status, "2") {
+			continue
+		}
+		if media, ok := op.Responses[status].Content["application/json"]; ok {
+			goType, err := g.goType(media.Schema)
+			if err != nil {
+				return fmt.Errorf("response %v: %v", status, err)
+			}
+			result = goType
+		}
+		break
+	}
+
+	// Build the path, escaping string parameters
+	format := pathParamPattern.ReplaceAllString(path, "%v")
+	values := []string{}
+	for _, match := range pathParamPattern.FindAllStringSubmatch(path, -1) {
+		param := match[1]
+		goType, ok := pathTypes[param]
+		if !ok {
+			return fmt.Errorf("Path parameter %v is not declared", param)
+		}
+		if goType == "string" {
+			values = append(values, fmt.Sprintf("url.PathEscape(%v)", param))
+		} else {
+			values = append(values, param)
+		}
+	}
+	pathExpr := fmt.Sprintf("%q", path)
+	if len(values) > 0 {
+		pathExpr = fmt.Sprintf("fmt.Sprintf(%q, %v)", format, strings.Join(values, ", "))
+	}
+
+	g.printf("// %v calls %v %v", name, strings.ToUpper(method), path)
+	if op.Summary != "" {
+		g.printf(" to %v", strings.ToLower(op.Summary[:1])+op.Summary[1:])
+	}
+	g.printf("\n")
+	if result == "" {
+		g.printf("func (v *V2Client) %v(%v) error {\n", name, strings.Join(args, ", "))
+	} else {
+		g.printf("func (v *V2Client) %v(%v) (%v, error) {\n", name, strings.Join(args, ", "), resultType(result))
+	}
+
+	g.printf("query := url.Values{}\n")
+	if len(queryParams) > 0 {
+		g.printf("if params != nil {\n")
+		for _, param := range queryParams {
+			field := exported(param.Name)
+			g.printf("if params.%v != nil {\nquery.Set(%q, fmt.Sprint(*params.%v))\n}\n", field, param.Name, field)
+		}
+		g.printf("}\n")
+	}
+
+	if result == "" {
+		g.printf("return v.call(%q, %v, query, %v, nil)\n}\n\n", strings.ToUpper(method), pathExpr, body)
+		return nil
+	}
+
+	if strings.HasPrefix(result, "[]") {
+		g.printf("result := %v{}\n", result)
+		g.printf("if err := v.call(%q, %v, query, %v, &result); err != nil {\nreturn nil, err\n}\n", strings.ToUpper(method), pathExpr, body)
+		g.printf("return result, nil\n}\n\n")
+	} else {
+		g.printf("result := &%v{}\n", result)
+		g.printf("if err := v.call(%q, %v, query, %v, result); err != nil {\nreturn nil, err\n}\n", strings.ToUpper(method), pathExpr, body)
+		g.printf("return result, nil\n}\n\n")
+	}
+
+	return nil
+}
+
+// resultType is the type a method returns for a response body type
+func resultType(goType string) string {
+	if strings.HasPrefix(goType, "[]") {
+		return goType
+	}
+	return "*" + goType
+}
+
+// comment writes a doc comment for name, using description if there is one and fallback otherwise
+func (g *generator) comment(name string, description string, fallback string) {
+	if description == "" {
+		g.printf("// %v %v\n", name, fallback)
+		return
+	}
+	g.printf("// %v is %v\n", name, strings.ToLower(description[:1])+description[1:])
+}
+
+// exported converts a camelCase name from the document into an exported Go name
+func exported(name string) string {
+	return strings.ToUpper(name[:1]) + name[1:]
+}
@@ -0,0 +1,180 @@
+// Package api holds the OpenAPI document describing 'Appened's REST API.
+// The document is the source of truth for request validation on the server and for the generated go-sdk.
+package api
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Spec is the OpenAPI 3 document, as served at /openapi.json
+//
+//go:embed openapi.json
+var Spec []byte
+
+// Document is the subset of an OpenAPI 3 document that 'Appened uses
+type Document struct {
+	OpenAPI    string                          `json:"openapi"`
+	Paths      map[string]map[string]Operation `json:"paths"` // Operations by path, then lowercase method
+	Components Components                      `json:"components"`
+}
+
+// Components holds the reusable parts of a Document
+type Components struct {
+	Parameters map[string]*Parameter `json:"parameters"`
+	Schemas    map[string]*Schema    `json:"schemas"`
+	Responses  map[string]*Response  `json:"responses"`
+}
+
+// Operation is a single method on a path
+type Operation struct {
+	OperationID string               `json:"operationId"`
+	Summary     string               `json:"summary"`
+	Parameters  []*Parameter         `json:"parameters"`
+	RequestBody *RequestBody         `json:"requestBody"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+// Parameter is a path or query parameter
+type Parameter struct {
+	Ref         string  `json:"$ref"`
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description"`
+	Required    bool    `json:"required"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody describes the body an operation accepts
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response describes a response an operation returns
+type Response struct {
+	Ref         string               `json:"$ref"`
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content"`
+}
+
+// MediaType is the schema of a body in a given content type
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Schema is the subset of JSON Schema that 'Appened uses
+type Schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Format               string             `json:"format"`
+	Description          string             `json:"description"`
+	Properties           map[string]*Schema `json:"properties"`
+	Required             []string           `json:"required"`
+	AdditionalProperties *bool              `json:"additionalProperties"`
+	Items                *Schema            `json:"items"`
+	Enum                 []interface{}      `json:"enum"`
+	Pattern              string             `json:"pattern"`
+	MinLength            *int               `json:"minLength"`
+	MaxLength            *int               `json:"maxLength"`
+	Minimum              *float64           `json:"minimum"`
+	Maximum              *float64           `json:"maximum"`
+	MinItems             *int               `json:"minItems"`
+}
+
+// Load parses Spec and resolves every $ref in it
+func Load() (*Document, error) {
+	return Parse(Spec)
+}
+
+// Parse parses an OpenAPI document and resolves every $ref in it
+func Parse(spec []byte) (*Document, error) {
+	doc := &Document{}
+	if err := json.Unmarshal(spec, doc); err != nil {
+		return nil, err
+	}
+
+	for path, item := range doc.Paths {
+		for method, op := range item {
+			for i, param := range op.Parameters {
+				resolved, err := doc.parameter(param)
+				if err != nil {
+					return nil, fmt.Errorf("%v %v: %v", strings.ToUpper(method), path, err)
+				}
+				op.Parameters[i] = resolved
+			}
+			for status, resp := range op.Responses {
+				resolved, err := doc.response(resp)
+				if err != nil {
+					return nil, fmt.Errorf("%v %v: %v", strings.ToUpper(method), path, err)
+				}
+				op.Responses[status] = resolved
+			}
+		}
+	}
+
+	return doc, nil
+}
+
+// Schema returns the schema a $ref points to, or s itself if it is not a reference
+func (d *Document) Schema(s *Schema) (*Schema, error) {
+	if s == nil || s.Ref == "" {
+		return s, nil
+	}
+
+	name, err := refName(s.Ref, "schemas")
+	if err != nil {
+		return nil, err
+	}
+	resolved, ok := d.Components.Schemas[name]
+	if !ok {
+		return nil, fmt.Errorf("Unknown schema %v", s.Ref)
+	}
+
+	return d.Schema(resolved)
+}
+
+func (d *Document) parameter(p *Parameter) (*Parameter, error) {
+	if p.Ref == "" {
+		return p, nil
+	}
+
+	name, err := refName(p.Ref, "parameters")
+	if err != nil {
+		return nil, err
+	}
+	resolved, ok := d.Components.Parameters[name]
+	if !ok {
+		return nil, fmt.Errorf("Unknown parameter %v", p.Ref)
+	}
+
+	return resolved, nil
+}
+
+func (d *Document) response(r *Response) (*Response, error) {
+	if r.Ref == "" {
+		return r, nil
+	}
+
+	name, err := refName(r.Ref, "responses")
+	if err != nil {
+		return nil, err
+	}
+	resolved, ok := d.Components.Responses[name]
+	if !ok {
+		return nil, fmt.Errorf("Unknown response %v", r.Ref)
+	}
+
+	return resolved, nil
+}
+
+// refName returns the name of a local reference to a component of the given kind
+func refName(ref string, kind string) (string, error) {
+	prefix := "#/components/" + kind + "/"
+	if !strings.HasPrefix(ref, prefix) {
+		return "", fmt.Errorf("Unsupported reference %v", ref)
+	}
+	return strings.TrimPrefix(ref, prefix), nil
+}
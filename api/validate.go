@@ -0,0 +1,294 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ValidationError describes how a request does not match the document
+type ValidationError struct {
+	Status int    // HTTP status to respond with, either 400 or 415
+	Detail string // What is wrong with the request
+}
+
+func (e *ValidationError) Error() string {
+	return e.Detail
+}
+
+func invalid(format string, a ...interface{}) *ValidationError {
+	return &ValidationError{http.StatusBadRequest, fmt.Sprintf(format, a...)}
+}
+
+// Validator checks requests against the operations described in a Document
+type Validator struct {
+	doc      *Document
+	patterns map[string]*regexp.Regexp
+}
+
+// NewValidator creates a Validator for doc, failing if the document uses anything the Validator cannot check
+func NewValidator(doc *Document) (*Validator, error) {
+	v := &Validator{doc, map[string]*regexp.Regexp{}}
+
+	for path, item := range doc.Paths {
+		for method, op := range item {
+			for _, param := range op.Parameters {
+				if param.In != "path" && param.In != "query" {
+					return nil, fmt.Errorf("%v %v: Unsupported parameter location %v", strings.ToUpper(method), path, param.In)
+				}
+				if err := v.compile(param.Schema, map[*Schema]bool{}); err != nil {
+					return nil, fmt.Errorf("%v %v: %v", strings.ToUpper(method), path, err)
+				}
+			}
+			if op.RequestBody == nil {
+				continue
+			}
+			for contentType, media := range op.RequestBody.Content {
+				if contentType != "application/json" {
+					return nil, fmt.Errorf("%v %v: Unsupported request content type %v", strings.ToUpper(method), path, contentType)
+				}
+				if err := v.compile(media.Schema, map[*Schema]bool{}); err != nil {
+					return nil, fmt.Errorf("%v %v: %v", strings.ToUpper(method), path, err)
+				}
+			}
+		}
+	}
+
+	return v, nil
+}
+
+// compile checks that a schema only uses supported types and compiles its patterns
+func (v *Validator) compile(s *Schema, seen map[*Schema]bool) error {
+	s, err := v.doc.Schema(s)
+	if err != nil || s == nil || seen[s] {
+		return err
+	}
+	seen[s] = true
+
+	switch s.Type {
+	case "object", "array", "string", "integer", "number", "boolean":
+	default:
+		return fmt.Errorf("Unsupported schema type %q", s.Type)
+	}
+
+	if s.Pattern != "" {
+		pattern, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return err
+		}
+		v.patterns[s.Pattern] = pattern
+	}
+
+	for _, property := range s.Properties {
+		if err := v.compile(property, seen); err != nil {
+			return err
+		}
+	}
+
+	return v.compile(s.Items, seen)
+}
+
+// Validate checks a request's parameters and body against the operation for pathTemplate and its method.
+// Requests the document does not describe are not checked. The body is replaced after it is read,
+// so handlers can still decode it.
+func (v *Validator) Validate(r *http.Request, pathTemplate string, pathParams map[string]string) error {
+	op, ok := v.doc.Paths[pathTemplate][strings.ToLower(r.Method)]
+	if !ok {
+		return nil
+	}
+
+	query := r.URL.Query()
+	for _, param := range op.Parameters {
+		var raw string
+		var present bool
+		if param.In == "path" {
+			raw, present = pathParams[param.Name]
+		} else {
+			present = query.Has(param.Name)
+			raw = query.Get(param.Name)
+		}
+
+		if !present {
+			if param.Required {
+				return invalid("%v parameter %v is required", param.In, param.Name)
+			}
+			continue
+		}
+
+		if err := v.validateParameter(param, raw); err != nil {
+			return err
+		}
+	}
+
+	if op.RequestBody == nil {
+		return nil
+	}
+	return v.validateBody(r, op.RequestBody)
+}
+
+// validateParameter converts a raw parameter to its schema's type and validates it
+func (v *Validator) validateParameter(param *Parameter, raw string) error {
+	schema, err := v.doc.Schema(param.Schema)
+	if err != nil || schema == nil {
+		return err
+	}
+
+	location := param.In + " parameter " + param.Name
+
+	var value interface{} = raw
+	switch schema.Type {
+	case "integer", "number":
+		if _, err := strconv.ParseFloat(raw, 64); err != nil {
+			return invalid("%v must be a number", location)
+		}
+		value = json.Number(raw)
+	case "boolean":
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return invalid("%v must be true or false", location)
+		}
+		value = b
+	}
+
+	return v.validateValue(schema, value, location)
+}
+
+// validateBody checks the request's content type and validates its JSON body
+func (v *Validator) validateBody(r *http.Request, body *RequestBody) error {
+	if r.ContentLength == 0 && r.Header.Get("Content-Type") == "" {
+		if body.Required {
+			return invalid("A request body is required")
+		}
+		return nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	media, ok := body.Content[mediaType]
+	if err != nil || !ok {
+		return &ValidationError{http.StatusUnsupportedMediaType, "Request body must be application/json"}
+	}
+
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return invalid("Could not read request body: %v", err)
+	}
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(data))
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return invalid("Invalid JSON body: %v", err)
+	}
+
+	return v.validateValue(media.Schema, value, "body")
+}
+
+// validateValue checks a decoded JSON value against a schema. location names the value in errors.
+func (v *Validator) validateValue(schema *Schema, value interface{}, location string) error {
+	schema, err := v.doc.Schema(schema)
+	if err != nil || schema == nil {
+		return err
+	}
+
+	if len(schema.Enum) > 0 {
+		allowed := false
+		for _, e := range schema.Enum {
+			allowed = allowed || fmt.Sprint(e) == fmt.Sprint(value)
+		}
+		if !allowed {
+			return invalid("%v must be one of %v", location, schema.Enum)
+		}
+	}
+
+	switch schema.Type {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return invalid("%v must be an object", location)
+		}
+		for _, name := range schema.Required {
+			if _, ok := object[name]; !ok {
+				return invalid("%v.%v is required", location, name)
+			}
+		}
+		for name, property := range object {
+			propertySchema, ok := schema.Properties[name]
+			if !ok {
+				if schema.AdditionalProperties != nil && !*schema.AdditionalProperties {
+					return invalid("%v.%v is not allowed", location, name)
+				}
+				continue
+			}
+			if err := v.validateValue(propertySchema, property, location+"."+name); err != nil {
+				return err
+			}
+		}
+
+	case "array":
+		array, ok := value.([]interface{})
+		if !ok {
+			return invalid("%v must be an array", location)
+		}
+		if schema.MinItems != nil && len(array) < *schema.MinItems {
+			return invalid("%v must have at least %v items", location, *schema.MinItems)
+		}
+		for i, item := range array {
+			if err := v.validateValue(schema.Items, item, fmt.Sprintf("%v[%v]", location, i)); err != nil {
+				return err
+			}
+		}
+
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			return invalid("%v must be a string", location)
+		}
+		length := utf8.RuneCountInString(s)
+		if schema.MinLength != nil && length < *schema.MinLength {
+			return invalid("%v must be at least %v characters", location, *schema.MinLength)
+		}
+		if schema.MaxLength != nil && length > *schema.MaxLength {
+			return invalid("%v must be at most %v characters", location, *schema.MaxLength)
+		}
+		if schema.Pattern != "" && !v.patterns[schema.Pattern].MatchString(s) {
+			return invalid("%v must match %v", location, schema.Pattern)
+		}
+
+	case "integer", "number":
+		n, ok := value.(json.Number)
+		if !ok {
+			return invalid("%v must be a number", location)
+		}
+		f, err := n.Float64()
+		if err != nil {
+			return invalid("%v must be a number", location)
+		}
+		if schema.Type == "integer" {
+			if _, err := n.Int64(); err != nil {
+				return invalid("%v must be an integer", location)
+			}
+		}
+		if schema.Minimum != nil && f < *schema.Minimum {
+			return invalid("%v must be at least %v", location, *schema.Minimum)
+		}
+		if schema.Maximum != nil && f > *schema.Maximum {
+			return invalid("%v must be at most %v", location, *schema.Maximum)
+		}
+
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return invalid("%v must be true or false", location)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,302 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// testValidator creates a Validator for the embedded document
+func testValidator(t *testing.T) *Validator {
+	t.Helper()
+
+	doc, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := NewValidator(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		template    string
+		params      map[string]string
+		target      string
+		contentType string
+		body        string
+		status      int // Status of the ValidationError, or 0 if the request is valid
+		detail      string
+	}{
+		{
+			name:     "valid parameters",
+			method:   http.MethodGet,
+			template: "/v2/folios/{name}",
+			params:   map[string]string{"name": "groceries"},
+			target:   "/v2/folios/groceries?done=true",
+		},
+		{
+			name:     "path parameter not matching its pattern",
+			method:   http.MethodGet,
+			template: "/v2/folios/{name}",
+			params:   map[string]string{"name": "groceries2"},
+			target:   "/v2/folios/groceries2",
+			status:   http.StatusBadRequest,
+			detail:   "path parameter name must match ^[a-zA-Z]+$",
+		},
+		{
+			name:     "missing path parameter",
+			method:   http.MethodDelete,
+			template: "/v2/folios/{name}",
+			target:   "/v2/folios/",
+			status:   http.StatusBadRequest,
+			detail:   "path parameter name is required",
+		},
+		{
+			name:     "query parameter of the wrong type",
+			method:   http.MethodGet,
+			template: "/v2/folios/{name}/notes",
+			params:   map[string]string{"name": "groceries"},
+			target:   "/v2/folios/groceries/notes?done=maybe",
+			status:   http.StatusBadRequest,
+			detail:   "query parameter done must be true or false",
+		},
+		{
+			name:     "index not a number",
+			method:   http.MethodGet,
+			template: "/v2/folios/{name}/notes/{index}",
+			params:   map[string]string{"name": "groceries", "index": "first"},
+			target:   "/v2/folios/groceries/notes/first",
+			status:   http.StatusBadRequest,
+			detail:   "path parameter index must be a number",
+		},
+		{
+			name:     "index not an integer",
+			method:   http.MethodGet,
+			template: "/v2/folios/{name}/notes/{index}",
+			params:   map[string]string{"name": "groceries", "index": "1.5"},
+			target:   "/v2/folios/groceries/notes/1.5",
+			status:   http.StatusBadRequest,
+			detail:   "path parameter index must be an integer",
+		},
+		{
+			name:     "index below its minimum",
+			method:   http.MethodGet,
+			template: "/v2/folios/{name}/notes/{index}",
+			params:   map[string]string{"name": "groceries", "index": "-1"},
+			target:   "/v2/folios/groceries/notes/-1",
+			status:   http.StatusBadRequest,
+			detail:   "path parameter index must be at least 0",
+		},
+		{
+			name:        "valid body",
+			method:      http.MethodPost,
+			template:    "/v2/folios",
+			target:      "/v2/folios",
+			contentType: "application/json; charset=utf-8",
+			body:        `{"name":"groceries"}`,
+		},
+		{
+			name:     "missing body",
+			method:   http.MethodPost,
+			template: "/v2/folios",
+			target:   "/v2/folios",
+			status:   http.StatusBadRequest,
+			detail:   "A request body is required",
+		},
+		{
+			name:        "body of another content type",
+			method:      http.MethodPost,
+			template:    "/v2/folios",
+			target:      "/v2/folios",
+			contentType: "application/x-www-form-urlencoded",
+			body:        "name=groceries",
+			status:      http.StatusUnsupportedMediaType,
+			detail:      "Request body must be application/json",
+		},
+		{
+			name:        "body not JSON",
+			method:      http.MethodPost,
+			template:    "/v2/folios",
+			target:      "/v2/folios",
+			contentType: "application/json",
+			body:        `{"name":`,
+			status:      http.StatusBadRequest,
+		},
+		{
+			name:        "body not an object",
+			method:      http.MethodPost,
+			template:    "/v2/folios",
+			target:      "/v2/folios",
+			contentType: "application/json",
+			body:        `["groceries"]`,
+			status:      http.StatusBadRequest,
+			detail:      "body must be an object",
+		},
+		{
+			name:        "missing property",
+			method:      http.MethodPost,
+			template:    "/v2/folios",
+			target:      "/v2/folios",
+			contentType: "application/json",
+			body:        `{}`,
+			status:      http.StatusBadRequest,
+			detail:      "body.name is required",
+		},
+		{
+			name:        "unknown property",
+			method:      http.MethodPost,
+			template:    "/v2/folios",
+			target:      "/v2/folios",
+			contentType: "application/json",
+			body:        `{"name":"groceries","colour":"green"}`,
+			status:      http.StatusBadRequest,
+			detail:      "body.colour is not allowed",
+		},
+		{
+			name:        "property of the wrong type",
+			method:      http.MethodPost,
+			template:    "/v2/folios/{name}/notes/{index}/done",
+			params:      map[string]string{"name": "groceries", "index": "0"},
+			target:      "/v2/folios/groceries/notes/0/done",
+			contentType: "application/json",
+			body:        `{"done":"yes"}`,
+			status:      http.StatusBadRequest,
+			detail:      "body.done must be true or false",
+		},
+		{
+			name:        "string too short",
+			method:      http.MethodPost,
+			template:    "/v2/folios/{name}/notes",
+			params:      map[string]string{"name": "groceries"},
+			target:      "/v2/folios/groceries/notes",
+			contentType: "application/json",
+			body:        `{"text":""}`,
+			status:      http.StatusBadRequest,
+			detail:      "body.text must be at least 1 characters",
+		},
+		{
+			name:        "valid batch",
+			method:      http.MethodPost,
+			template:    "/v2/batch",
+			target:      "/v2/batch",
+			contentType: "application/json",
+			body:        `{"operations":[{"op":"append","folio":"groceries","note":"milk"},{"op":"done","folio":"groceries","index":0}]}`,
+		},
+		{
+			name:        "too few items",
+			method:      http.MethodPost,
+			template:    "/v2/batch",
+			target:      "/v2/batch",
+			contentType: "application/json",
+			body:        `{"operations":[]}`,
+			status:      http.StatusBadRequest,
+			detail:      "body.operations must have at least 1 items",
+		},
+		{
+			name:        "item not in its enum",
+			method:      http.MethodPost,
+			template:    "/v2/batch",
+			target:      "/v2/batch",
+			contentType: "application/json",
+			body:        `{"operations":[{"op":"append","folio":"groceries"},{"op":"delete","folio":"groceries"}]}`,
+			status:      http.StatusBadRequest,
+			detail:      "body.operations[1].op must be one of [append edit done]",
+		},
+		{
+			name:        "item index of the wrong type",
+			method:      http.MethodPost,
+			template:    "/v2/batch",
+			target:      "/v2/batch",
+			contentType: "application/json",
+			body:        `{"operations":[{"op":"done","folio":"groceries","index":"0"}]}`,
+			status:      http.StatusBadRequest,
+			detail:      "body.operations[0].index must be a number",
+		},
+		{
+			name:     "undescribed request",
+			method:   http.MethodPut,
+			template: "/v2/folios",
+			target:   "/v2/folios",
+			body:     "anything",
+		},
+	}
+
+	v := testValidator(t)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(test.method, test.target, strings.NewReader(test.body))
+			if test.contentType != "" {
+				r.Header.Set("Content-Type", test.contentType)
+			}
+
+			err := v.Validate(r, test.template, test.params)
+			if test.status == 0 {
+				if err != nil {
+					t.Fatalf("Got error %v, want none", err)
+				}
+				// The body can still be read after validation
+				if body, _ := io.ReadAll(r.Body); string(body) != test.body {
+					t.Errorf("Body after validation is %q, want %q", body, test.body)
+				}
+				return
+			}
+
+			verr := &ValidationError{}
+			if !errors.As(err, &verr) {
+				t.Fatalf("Got error %v, want a *ValidationError", err)
+			}
+			if verr.Status != test.status || (test.detail != "" && verr.Detail != test.detail) {
+				t.Errorf("Got %v %q, want %v %q", verr.Status, verr.Detail, test.status, test.detail)
+			}
+		})
+	}
+}
+
+func TestNewValidatorRejectsUnsupported(t *testing.T) {
+	tests := []struct {
+		name string
+		spec string
+	}{
+		{
+			name: "header parameter",
+			spec: `{"paths":{"/":{"get":{"parameters":[{"name":"token","in":"header","schema":{"type":"string"}}]}}}}`,
+		},
+		{
+			name: "request content type",
+			spec: `{"paths":{"/":{"post":{"requestBody":{"content":{"text/plain":{"schema":{"type":"string"}}}}}}}}`,
+		},
+		{
+			name: "schema type",
+			spec: `{"paths":{"/":{"post":{"requestBody":{"content":{"application/json":{"schema":{"type":"null"}}}}}}}}`,
+		},
+		{
+			name: "pattern",
+			spec: `{"paths":{"/":{"get":{"parameters":[{"name":"q","in":"query","schema":{"type":"string","pattern":"("}}]}}}}`,
+		},
+		{
+			name: "unknown reference",
+			spec: `{"paths":{"/":{"post":{"requestBody":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/Missing"}}}}}}}}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			doc, err := Parse([]byte(test.spec))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := NewValidator(doc); err == nil {
+				t.Error("Created a validator, want an error")
+			}
+		})
+	}
+}
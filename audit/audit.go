@@ -0,0 +1,315 @@
+// Package audit keeps a tamper-evident record of changes made through 'Appened.
+//
+// Entries are appended to a file as JSON lines. Each entry includes the hash of the entry before it,
+// and its own hash covers that, so editing, removing or reordering entries breaks the chain from that
+// point on, which Verify detects.
+package audit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// genesisHash is the previous hash of the first entry in a log
+const genesisHash = "0000000000000000000000000000000000000000000000000000000000000000"
+
+// Entry is a single change recorded in the audit log
+type Entry struct {
+	Seq        int64           `json:"seq"`                  // Position in the log, starting at 1
+	Time       time.Time       `json:"time"`                 // When the change was made, in UTC
+	Actor      string          `json:"actor"`                // Name of the identity that made the change, empty if unauthenticated
+	OnBehalfOf string          `json:"onBehalfOf,omitempty"` // Who the actor says it acted for, e.g. a phone number texting the Twilio client
+	Remote     string          `json:"remote,omitempty"`     // Address the request came from
+	RequestID  string          `json:"requestId,omitempty"`
+	Action     string          `json:"action"` // What was done, e.g. create, append, edit, done, delete, undo
+	Folio      string          `json:"folio,omitempty"`
+	Before     json.RawMessage `json:"before,omitempty"` // Values that changed, as they were before
+	After      json.RawMessage `json:"after,omitempty"`  // Values that changed, as they are now
+	PrevHash   string          `json:"prevHash"`         // Hash of the previous entry
+	Hash       string          `json:"hash"`             // Hash of this entry, including PrevHash
+}
+
+// hash returns the hash an entry should have
+func (e Entry) hash() (string, error) {
+	e.Hash = ""
+	data, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Log is an append-only audit log file
+type Log struct {
+	path     string
+	mu       *sync.Mutex
+	file     *os.File
+	seq      int64    // Seq of the last entry
+	lastHash string   // Hash of the last entry
+	repairs  []string // Damage found and worked around when the log was opened
+}
+
+// Open opens the audit log at path, creating it if it does not exist. The log is left usable if it was
+// damaged: a last line cut short by a crash while it was written is removed, and lines that are not valid
+// entries are skipped, continuing the chain from the last valid entry. What was done is returned by Repairs,
+// and VerifyFile still reports a broken chain.
+func Open(path string) (*Log, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &Log{path: path, mu: &sync.Mutex{}, file: file, lastHash: genesisHash}
+
+	// Continue the chain from the last entry
+	if err = l.resume(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("Reading audit log %v: %w", path, err)
+	}
+
+	return l, nil
+}
+
+// resume reads every entry in the log to find the last one, removing a torn last line so the next
+// entry starts on a line of its own
+func (l *Log) resume() error {
+	reader := bufio.NewReader(l.file)
+	offset, line := int64(0), 0
+	for {
+		data, err := reader.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		if len(data) == 0 {
+			return nil
+		}
+		line++
+
+		e := Entry{}
+		valid := json.Unmarshal(data, &e) == nil
+
+		// Record writes a whole line at once, so a last line without a newline was cut short
+		if errors.Is(err, io.EOF) {
+			if !valid {
+				l.repairs = append(l.repairs, fmt.Sprintf("Removed line %v, which was not completely written", line))
+				return l.file.Truncate(offset)
+			}
+			if _, err := l.file.Write([]byte{'\n'}); err != nil {
+				return err
+			}
+		}
+
+		offset += int64(len(data))
+		if !valid {
+			l.repairs = append(l.repairs, fmt.Sprintf("Skipped line %v, which is not a valid entry", line))
+			continue
+		}
+		l.seq, l.lastHash = e.Seq, e.Hash
+	}
+}
+
+// Repairs describes damage to the log that was found and worked around when it was opened
+func (l *Log) Repairs() []string {
+	return l.repairs
+}
+
+// Record appends an entry to the log, filling in its sequence number, time and hashes
+func (l *Log) Record(e Entry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return os.ErrClosed
+	}
+
+	e.Seq = l.seq + 1
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	e.Time = e.Time.UTC()
+	e.PrevHash = l.lastHash
+
+	hash, err := e.hash()
+	if err != nil {
+		return err
+	}
+	e.Hash = hash
+
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if _, err = l.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	if err = l.file.Sync(); err != nil {
+		return err
+	}
+
+	l.seq, l.lastHash = e.Seq, e.Hash
+	return nil
+}
+
+// Filter selects entries from the log. Zero values match every entry.
+type Filter struct {
+	Folio  string
+	Actor  string // Matches Actor or OnBehalfOf
+	Action string
+	Since  time.Time
+	Until  time.Time
+	Limit  int // Return only the most recent Limit matching entries
+}
+
+func (f Filter) matches(e Entry) bool {
+	switch {
+	case f.Folio != "" && e.Folio != f.Folio:
+		return false
+	case f.Actor != "" && e.Actor != f.Actor && e.OnBehalfOf != f.Actor:
+		return false
+	case f.Action != "" && e.Action != f.Action:
+		return false
+	case !f.Since.IsZero() && e.Time.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && e.Time.After(f.Until):
+		return false
+	}
+	return true
+}
+
+// Query returns the entries matching filter, oldest first. Lines that are not valid entries are
+// skipped, as they are when the log is opened.
+func (l *Log) Query(filter Filter) ([]Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	file, err := os.Open(l.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	entries := []Entry{}
+	err = scan(file, true, func(e Entry) bool {
+		if filter.matches(e) {
+			entries = append(entries, e)
+			if filter.Limit > 0 && len(entries) > filter.Limit {
+				entries = entries[1:]
+			}
+		}
+		return true
+	})
+
+	return entries, err
+}
+
+// Close closes the log
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// VerifyError describes where an audit log's hash chain is broken
+type VerifyError struct {
+	Line   int // Line of the first entry that does not verify, starting at 1
+	Reason string
+}
+
+func (e *VerifyError) Error() string {
+	return fmt.Sprintf("audit log line %v: %v", e.Line, e.Reason)
+}
+
+// Verify checks that every entry read from r is intact and follows the one before it.
+// It returns the number of entries verified, and a *VerifyError if the chain is broken.
+func Verify(r io.Reader) (int, error) {
+	count, prevHash := 0, genesisHash
+	var verifyErr *VerifyError
+
+	err := scan(r, false, func(e Entry) bool {
+		line := count + 1
+		hash, err := e.hash()
+		switch {
+		case err != nil:
+			verifyErr = &VerifyError{line, err.Error()}
+		case e.Seq != int64(line):
+			verifyErr = &VerifyError{line, fmt.Sprintf("sequence number is %v, expected %v", e.Seq, line)}
+		case e.PrevHash != prevHash:
+			verifyErr = &VerifyError{line, "previous hash does not match the entry before it"}
+		case e.Hash != hash:
+			verifyErr = &VerifyError{line, "hash does not match the entry's contents"}
+		default:
+			count, prevHash = line, e.Hash
+			return true
+		}
+		return false
+	})
+
+	var lineErr *VerifyError
+	if errors.As(err, &lineErr) {
+		return count, lineErr
+	}
+	if err != nil {
+		return count, err
+	}
+	if verifyErr != nil {
+		return count, verifyErr
+	}
+	return count, nil
+}
+
+// VerifyFile verifies the audit log at path
+func VerifyFile(path string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	return Verify(file)
+}
+
+// scan calls fn with each entry read from r until fn returns false. A line that is not a valid
+// entry is skipped if skipInvalid is set, and returned as a *VerifyError otherwise.
+func scan(r io.Reader, skipInvalid bool, fn func(Entry) bool) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		e := Entry{}
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			if skipInvalid {
+				continue
+			}
+			return &VerifyError{line, fmt.Sprintf("not a valid entry: %v", err)}
+		}
+		if !fn(e) {
+			return nil
+		}
+	}
+
+	return scanner.Err()
+}
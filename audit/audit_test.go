@@ -0,0 +1,300 @@
+package audit
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testLog opens an empty log in a temporary directory, closing it when the test ends
+func testLog(t *testing.T) (*Log, string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "audit", "audit.log")
+	l, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	return l, path
+}
+
+// record appends entries to l, failing the test if any can't be
+func record(t *testing.T, l *Log, entries ...Entry) {
+	t.Helper()
+
+	for _, e := range entries {
+		if err := l.Record(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// actions returns the action of each entry, in order
+func actions(entries []Entry) []string {
+	list := []string{}
+	for _, e := range entries {
+		list = append(list, e.Action)
+	}
+	return list
+}
+
+// readLines returns the lines of the file at path
+func readLines(t *testing.T, path string) []string {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+// writeLines replaces the file at path with lines, leaving the last without a newline if torn
+func writeLines(t *testing.T, path string, lines []string, torn bool) {
+	t.Helper()
+
+	data := strings.Join(lines, "\n")
+	if !torn {
+		data += "\n"
+	}
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRecordChainsEntries(t *testing.T) {
+	l, path := testLog(t)
+	record(t, l, Entry{Action: "create", Folio: "groceries"}, Entry{Action: "append", Folio: "groceries"})
+
+	entries, err := l.Query(Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Got %v entries, want 2", len(entries))
+	}
+	if entries[0].Seq != 1 || entries[0].PrevHash != genesisHash {
+		t.Errorf("First entry has seq %v and previous hash %v", entries[0].Seq, entries[0].PrevHash)
+	}
+	if entries[1].Seq != 2 || entries[1].PrevHash != entries[0].Hash {
+		t.Errorf("Second entry has seq %v and does not follow the first", entries[1].Seq)
+	}
+	if entries[0].Time.Location() != time.UTC {
+		t.Errorf("Entry time %v is not in UTC", entries[0].Time)
+	}
+
+	if count, err := VerifyFile(path); err != nil || count != 2 {
+		t.Errorf("Verified %v entries with error %v, want 2 and none", count, err)
+	}
+}
+
+func TestReopenContinuesChain(t *testing.T) {
+	l, path := testLog(t)
+	record(t, l, Entry{Action: "create"})
+	l.Close()
+
+	if err := l.Record(Entry{Action: "append"}); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Recording to a closed log got error %v, want %v", err, os.ErrClosed)
+	}
+
+	l, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	record(t, l, Entry{Action: "append"})
+
+	if count, err := VerifyFile(path); err != nil || count != 2 {
+		t.Errorf("Verified %v entries with error %v, want 2 and none", count, err)
+	}
+}
+
+func TestVerifyDetectsTampering(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(lines []string) []string
+		count  int // Entries verified before the break
+		line   int // Line the break is reported on
+	}{
+		{
+			name: "edited entry",
+			tamper: func(lines []string) []string {
+				lines[1] = strings.Replace(lines[1], `"folio":"groceries"`, `"folio":"chores"`, 1)
+				return lines
+			},
+			count: 1,
+			line:  2,
+		},
+		{
+			name: "removed entry",
+			tamper: func(lines []string) []string {
+				return append(lines[:1], lines[2:]...)
+			},
+			count: 1,
+			line:  2,
+		},
+		{
+			name: "reordered entries",
+			tamper: func(lines []string) []string {
+				lines[1], lines[2] = lines[2], lines[1]
+				return lines
+			},
+			count: 1,
+			line:  2,
+		},
+		{
+			name: "line that is not an entry",
+			tamper: func(lines []string) []string {
+				lines[2] = "not json"
+				return lines
+			},
+			count: 2,
+			line:  3,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			l, path := testLog(t)
+			record(t, l, Entry{Action: "create", Folio: "groceries"}, Entry{Action: "append", Folio: "groceries"}, Entry{Action: "done", Folio: "groceries"})
+			writeLines(t, path, test.tamper(readLines(t, path)), false)
+
+			count, err := VerifyFile(path)
+			verifyErr := &VerifyError{}
+			if !errors.As(err, &verifyErr) {
+				t.Fatalf("Got error %v, want a *VerifyError", err)
+			}
+			if count != test.count || verifyErr.Line != test.line {
+				t.Errorf("Verified %v entries and broke on line %v, want %v and line %v", count, verifyErr.Line, test.count, test.line)
+			}
+		})
+	}
+}
+
+func TestOpenRepairs(t *testing.T) {
+	tests := []struct {
+		name    string
+		damage  func(lines []string) ([]string, bool) // Returns the damaged lines, and whether the last is torn
+		repairs []string
+		actions []string // Actions queried after repairing and recording "undo"
+		lines   int      // Lines in the file afterwards
+	}{
+		{
+			name:    "undamaged",
+			damage:  func(lines []string) ([]string, bool) { return lines, false },
+			actions: []string{"create", "append", "done", "undo"},
+			lines:   4,
+		},
+		{
+			name: "torn last line",
+			damage: func(lines []string) ([]string, bool) {
+				lines[2] = lines[2][:len(lines[2])/2]
+				return lines, true
+			},
+			repairs: []string{"Removed line 3, which was not completely written"},
+			actions: []string{"create", "append", "undo"},
+			lines:   3,
+		},
+		{
+			name: "complete last line without a newline",
+			damage: func(lines []string) ([]string, bool) {
+				return lines, true
+			},
+			actions: []string{"create", "append", "done", "undo"},
+			lines:   4,
+		},
+		{
+			name: "corrupt middle line",
+			damage: func(lines []string) ([]string, bool) {
+				lines[1] = "not json"
+				return lines, false
+			},
+			repairs: []string{"Skipped line 2, which is not a valid entry"},
+			actions: []string{"create", "done", "undo"},
+			lines:   4,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			l, path := testLog(t)
+			record(t, l, Entry{Action: "create"}, Entry{Action: "append"}, Entry{Action: "done"})
+			l.Close()
+
+			lines, torn := test.damage(readLines(t, path))
+			writeLines(t, path, lines, torn)
+
+			l, err := Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer l.Close()
+
+			if repairs := l.Repairs(); !reflect.DeepEqual(repairs, test.repairs) {
+				t.Errorf("Got repairs %q, want %q", repairs, test.repairs)
+			}
+
+			// The log is still usable, and entries recorded after a repair start on a line of their own
+			record(t, l, Entry{Action: "undo"})
+			entries, err := l.Query(Filter{})
+			if err != nil {
+				t.Fatalf("Querying a repaired log: %v", err)
+			}
+			if got := actions(entries); !reflect.DeepEqual(got, test.actions) {
+				t.Errorf("Queried actions %q, want %q", got, test.actions)
+			}
+			if got := len(readLines(t, path)); got != test.lines {
+				t.Errorf("Log has %v lines, want %v", got, test.lines)
+			}
+		})
+	}
+}
+
+func TestQuery(t *testing.T) {
+	l, _ := testLog(t)
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	record(t, l,
+		Entry{Time: start, Actor: "web", Action: "create", Folio: "groceries"},
+		Entry{Time: start.Add(time.Hour), Actor: "twilio", OnBehalfOf: "+15550001111", Action: "append", Folio: "groceries"},
+		Entry{Time: start.Add(2 * time.Hour), Actor: "web", Action: "create", Folio: "chores"},
+		Entry{Time: start.Add(3 * time.Hour), Actor: "web", Action: "append", Folio: "chores"},
+	)
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []int64 // Seq of each entry returned
+	}{
+		{"everything", Filter{}, []int64{1, 2, 3, 4}},
+		{"folio", Filter{Folio: "groceries"}, []int64{1, 2}},
+		{"actor", Filter{Actor: "web"}, []int64{1, 3, 4}},
+		{"on behalf of", Filter{Actor: "+15550001111"}, []int64{2}},
+		{"action", Filter{Action: "create"}, []int64{1, 3}},
+		{"since", Filter{Since: start.Add(2 * time.Hour)}, []int64{3, 4}},
+		{"until", Filter{Until: start.Add(time.Hour)}, []int64{1, 2}},
+		{"limit keeps the most recent", Filter{Limit: 2}, []int64{3, 4}},
+		{"combined", Filter{Actor: "web", Action: "append"}, []int64{4}},
+		{"no match", Filter{Folio: "work"}, []int64{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			entries, err := l.Query(test.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []int64{}
+			for _, e := range entries {
+				got = append(got, e.Seq)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Got entries %v, want %v", got, test.want)
+			}
+		})
+	}
+}
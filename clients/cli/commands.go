@@ -0,0 +1,413 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	appendedGo "github.com/appened/clients/go-sdk"
+)
+
+// parseFlags parses a command's flags, requiring at least min arguments to follow them
+func parseFlags(flags *flag.FlagSet, args []string, min int, usage string) ([]string, error) {
+	flags.SetOutput(io.Discard)
+	if err := flags.Parse(args); err != nil {
+		return nil, fmt.Errorf("%v\nusage: appened %v %v", err, flags.Name(), usage)
+	}
+	if flags.NArg() < min {
+		return nil, fmt.Errorf("usage: appened %v %v", flags.Name(), usage)
+	}
+	return flags.Args(), nil
+}
+
+// noteIndex converts a note number, as listed, to its index
+func noteIndex(number string) (int, error) {
+	n, err := strconv.Atoi(number)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("Invalid note number %q, notes are numbered from 1", number)
+	}
+	return n - 1, nil
+}
+
+// doneFilter returns the filter set by -done or -undone
+func doneFilter(done bool, undone bool) (*bool, error) {
+	switch {
+	case done && undone:
+		return nil, errors.New("Use only one of -done and -undone")
+	case done, undone:
+		return &done, nil
+	}
+	return nil, nil
+}
+
+func listFolios(a *app, args []string) error {
+	folios, err := a.client.V2().ListFolios()
+	if err != nil {
+		return err
+	}
+	return a.out.folios(folios)
+}
+
+func createFolio(a *app, args []string) error {
+	args, err := parseFlags(flag.NewFlagSet("create", flag.ContinueOnError), args, 1, "<folio>")
+	if err != nil {
+		return err
+	}
+
+	folio, err := a.client.V2().CreateFolio(appendedGo.CreateFolioRequest{Name: args[0]})
+	if err != nil {
+		return err
+	}
+	return a.out.value(folio, func(w io.Writer) {
+		fmt.Fprintf(w, "Created folio %v\n", folio.Name)
+	})
+}
+
+func deleteFolio(a *app, args []string) error {
+	flags := flag.NewFlagSet("delete", flag.ContinueOnError)
+	yes := flags.Bool("y", false, "")
+	args, err := parseFlags(flags, args, 1, "[-y] <folio>")
+	if err != nil {
+		return err
+	}
+	name := args[0]
+
+	if !*yes {
+		fmt.Fprintf(a.errOut, "Delete folio %v and all of its notes? [y/N] ", name)
+		answer, _ := bufio.NewReader(a.in).ReadString('\n')
+		if strings.ToLower(strings.TrimSpace(answer)) != "y" {
+			return errors.New("Not deleted")
+		}
+	}
+
+	if err := a.client.V2().DeleteFolio(name); err != nil {
+		return err
+	}
+	return a.out.message("Deleted folio " + name)
+}
+
+func listNotes(a *app, args []string) error {
+	flags := flag.NewFlagSet("notes", flag.ContinueOnError)
+	done := flags.Bool("done", false, "")
+	undone := flags.Bool("undone", false, "")
+	args, err := parseFlags(flags, args, 1, "[-done | -undone] <folio>")
+	if err != nil {
+		return err
+	}
+
+	filter, err := doneFilter(*done, *undone)
+	if err != nil {
+		return err
+	}
+
+	notes, err := a.client.V2().ListNotes(args[0], &appendedGo.ListNotesParams{Done: filter})
+	if err != nil {
+		return err
+	}
+	return a.out.notes(notes)
+}
+
+func appendNotes(a *app, args []string) error {
+	args, err := parseFlags(flag.NewFlagSet("append", flag.ContinueOnError), args, 1, "<folio> [text...]")
+	if err != nil {
+		return err
+	}
+	name := args[0]
+
+	// Append the text given, or each line of stdin
+	texts := []string{strings.Join(args[1:], " ")}
+	if len(args) == 1 || (len(args) == 2 && args[1] == "-") {
+		texts = []string{}
+		scanner := bufio.NewScanner(a.in)
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" {
+				texts = append(texts, line)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+	}
+
+	switch len(texts) {
+	case 0:
+		return errors.New("Nothing to append")
+	case 1:
+		n, err := a.client.V2().AppendNote(name, appendedGo.NoteText{Text: texts[0]})
+		if err != nil {
+			return err
+		}
+		return a.out.value(n, func(w io.Writer) {
+			fmt.Fprintf(w, "Appended note %v to %v\n", n.Index+1, name)
+		})
+	}
+
+	// Append several notes in one request, so that either all or none of them are appended
+	batch := a.client.NewBatch()
+	for _, text := range texts {
+		batch.AddNote(name, text)
+	}
+	results, err := batch.Send()
+	if err != nil {
+		return err
+	}
+	for _, result := range results {
+		if err := result.Err(); err != nil {
+			return err
+		}
+	}
+	return a.out.message(fmt.Sprintf("Appended %v notes to %v", len(texts), name))
+}
+
+func editNote(a *app, args []string) error {
+	args, err := parseFlags(flag.NewFlagSet("edit", flag.ContinueOnError), args, 3, "<folio> <#> <text...>")
+	if err != nil {
+		return err
+	}
+
+	index, err := noteIndex(args[1])
+	if err != nil {
+		return err
+	}
+
+	n, err := a.client.V2().EditNote(args[0], index, appendedGo.NoteText{Text: strings.Join(args[2:], " ")})
+	if err != nil {
+		return err
+	}
+	return a.out.value(n, func(w io.Writer) {
+		fmt.Fprintf(w, "Edited note %v in %v\n", n.Index+1, args[0])
+	})
+}
+
+func toggleDone(a *app, args []string) error {
+	args, err := parseFlags(flag.NewFlagSet("done", flag.ContinueOnError), args, 2, "<folio> <#>...")
+	if err != nil {
+		return err
+	}
+	name := args[0]
+
+	notes := []appendedGo.Note{}
+	for _, number := range args[1:] {
+		index, err := noteIndex(number)
+		if err != nil {
+			return err
+		}
+
+		n, err := a.client.V2().GetNote(name, index)
+		if err != nil {
+			return err
+		}
+		if n, err = a.client.V2().SetNoteDone(name, index, appendedGo.SetDoneRequest{Done: !n.Done}); err != nil {
+			return err
+		}
+		notes = append(notes, *n)
+	}
+
+	return a.out.notes(notes)
+}
+
+func undo(a *app, args []string) error {
+	args, err := parseFlags(flag.NewFlagSet("undo", flag.ContinueOnError), args, 1, "<folio>")
+	if err != nil {
+		return err
+	}
+
+	result, err := a.client.V2().UndoFolio(args[0])
+	if err != nil {
+		return err
+	}
+	return a.out.value(result, func(w io.Writer) {
+		fmt.Fprintf(w, "Undid %v\n", result.Undone)
+	})
+}
+
+func redo(a *app, args []string) error {
+	args, err := parseFlags(flag.NewFlagSet("redo", flag.ContinueOnError), args, 1, "<folio>")
+	if err != nil {
+		return err
+	}
+
+	result, err := a.client.V2().RedoFolio(args[0])
+	if err != nil {
+		return err
+	}
+	return a.out.value(result, func(w io.Writer) {
+		fmt.Fprintf(w, "Redid %v\n", result.Redone)
+	})
+}
+
+// match is a note found by search
+type match struct {
+	Folio string          `json:"folio"`
+	Note  appendedGo.Note `json:"note"`
+}
+
+func search(a *app, args []string) error {
+	flags := flag.NewFlagSet("search", flag.ContinueOnError)
+	folio := flags.String("folio", "", "")
+	done := flags.Bool("done", false, "")
+	undone := flags.Bool("undone", false, "")
+	args, err := parseFlags(flags, args, 1, "[-folio <folio>] [-done | -undone] <text>")
+	if err != nil {
+		return err
+	}
+
+	filter, err := doneFilter(*done, *undone)
+	if err != nil {
+		return err
+	}
+
+	names := []string{*folio}
+	if *folio == "" {
+		if names, err = folioNames(a); err != nil {
+			return err
+		}
+	}
+
+	query := strings.ToLower(strings.Join(args, " "))
+	matches := []match{}
+	for _, name := range names {
+		notes, err := a.client.V2().ListNotes(name, &appendedGo.ListNotesParams{Done: filter})
+		if err != nil {
+			return err
+		}
+		for _, n := range notes {
+			if strings.Contains(strings.ToLower(n.Text), query) {
+				matches = append(matches, match{name, n})
+			}
+		}
+	}
+
+	return a.out.matches(matches)
+}
+
+func export(a *app, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := flags.String("format", "json", "")
+	names, err := parseFlags(flags, args, 0, "[-format json|csv|markdown] [folio...]")
+	if err != nil {
+		return err
+	}
+
+	if len(names) == 0 {
+		if names, err = folioNames(a); err != nil {
+			return err
+		}
+	}
+
+	folios := []appendedGo.Folio{}
+	for _, name := range names {
+		folio, err := a.client.V2().GetFolio(name, nil)
+		if err != nil {
+			return err
+		}
+		folios = append(folios, *folio)
+	}
+
+	switch *format {
+	case "json":
+		return printer{a.out.out, true}.value(folios, nil)
+	case "csv":
+		w := csv.NewWriter(a.out.out)
+		w.Write([]string{"folio", "number", "text", "done", "dateCreated", "dateDone", "dateEdited"})
+		for _, folio := range folios {
+			for _, n := range folio.Notes {
+				w.Write([]string{
+					folio.Name,
+					strconv.Itoa(n.Index + 1),
+					n.Text,
+					strconv.FormatBool(n.Done),
+					strconv.FormatInt(n.DateCreated, 10),
+					strconv.FormatInt(n.DateDone, 10),
+					strconv.FormatInt(n.DateEdited, 10),
+				})
+			}
+		}
+		w.Flush()
+		return w.Error()
+	case "markdown":
+		for i, folio := range folios {
+			if i > 0 {
+				fmt.Fprintln(a.out.out)
+			}
+			fmt.Fprintf(a.out.out, "# %v\n\n", folio.Name)
+			for _, n := range folio.Notes {
+				box := " "
+				if n.Done {
+					box = "x"
+				}
+				fmt.Fprintf(a.out.out, "- [%v] %v\n", box, escapeMarkdown(n.Text))
+			}
+		}
+		return nil
+	}
+
+	return fmt.Errorf("Unknown export format %q, must be json, csv, or markdown", *format)
+}
+
+func configure(a *app, args []string) error {
+	flags := flag.NewFlagSet("config", flag.ContinueOnError)
+	url := flags.String("url", "", "")
+	token := flags.String("token", "", "")
+	ca := flags.String("ca", "", "")
+	cert := flags.String("cert", "", "")
+	key := flags.String("key", "", "")
+	output := flags.String("output", "", "")
+	if _, err := parseFlags(flags, args, 0, "[-url <url>] [-token <token>] [-ca <file>] [-cert <file> -key <file>] [-output table|json]"); err != nil {
+		return err
+	}
+
+	// Only settings given as flags are changed, so read the file without flag or environment overrides
+	config, err := readConfig(a.configPath)
+	if err != nil {
+		return err
+	}
+	changed := false
+	for _, setting := range []struct {
+		value string
+		field *string
+	}{{*url, &config.URL}, {*token, &config.Token}, {*ca, &config.CAFile}, {*cert, &config.ClientCert}, {*key, &config.ClientKey}, {*output, &config.Output}} {
+		if setting.value != "" {
+			*setting.field, changed = setting.value, true
+		}
+	}
+
+	if changed {
+		if err := saveConfig(a.configPath, config); err != nil {
+			return err
+		}
+	}
+
+	// Never print the token itself
+	if config.Token != "" {
+		config.Token = "(set)"
+	}
+	return a.out.value(config, func(w io.Writer) {
+		fmt.Fprintf(w, "file\t%v\n", a.configPath)
+		fmt.Fprintf(w, "url\t%v\n", config.URL)
+		fmt.Fprintf(w, "token\t%v\n", config.Token)
+		fmt.Fprintf(w, "ca\t%v\n", config.CAFile)
+		fmt.Fprintf(w, "cert\t%v\n", config.ClientCert)
+		fmt.Fprintf(w, "key\t%v\n", config.ClientKey)
+		fmt.Fprintf(w, "output\t%v\n", config.Output)
+	})
+}
+
+// folioNames returns the name of every folio
+func folioNames(a *app) ([]string, error) {
+	folios, err := a.client.V2().ListFolios()
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, len(folios))
+	for i, folio := range folios {
+		names[i] = folio.Name
+	}
+	return names, nil
+}
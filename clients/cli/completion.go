@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// completion prints a completion script for a shell. Folio names are completed by running `appened __folios`.
+func completion(a *app, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: appened completion bash|zsh|fish")
+	}
+
+	folioCommands := []string{}
+	for _, cmd := range commands {
+		if cmd.folioArgs {
+			folioCommands = append(folioCommands, cmd.name)
+			folioCommands = append(folioCommands, cmd.aliases...)
+		}
+	}
+	names := strings.Join(commandNames(), " ")
+
+	switch args[0] {
+	case "bash":
+		fmt.Fprintf(a.out.out, `_appened() {
+	local cur="${COMP_WORDS[COMP_CWORD]}"
+	if [ "$COMP_CWORD" -eq 1 ]; then
+		COMPREPLY=($(compgen -W "%v" -- "$cur"))
+		return
+	fi
+	case "${COMP_WORDS[1]}" in
+	%v)
+		COMPREPLY=($(compgen -W "$(appened __folios 2>/dev/null)" -- "$cur"))
+		;;
+	esac
+}
+complete -F _appened appened
+`, names, strings.Join(folioCommands, "|"))
+	case "zsh":
+		fmt.Fprintf(a.out.out, `#compdef appened
+_appened() {
+	if (( CURRENT == 2 )); then
+		compadd -- %v
+		return
+	fi
+	case "${words[2]}" in
+	%v)
+		compadd -- ${(f)"$(appened __folios 2>/dev/null)"}
+		;;
+	esac
+}
+compdef _appened appened
+`, names, strings.Join(folioCommands, "|"))
+	case "fish":
+		fmt.Fprintf(a.out.out, "complete -c appened -f\n")
+		fmt.Fprintf(a.out.out, "complete -c appened -n __fish_use_subcommand -a '%v'\n", names)
+		fmt.Fprintf(a.out.out, "complete -c appened -n '__fish_seen_subcommand_from %v' -a '(appened __folios 2>/dev/null)'\n", strings.Join(folioCommands, " "))
+	default:
+		return fmt.Errorf("Unknown shell %q, must be bash, zsh, or fish", args[0])
+	}
+	return nil
+}
+
+// completeFolios prints each folio name on its own line, for completion scripts
+func completeFolios(a *app, args []string) error {
+	names, err := folioNames(a)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		fmt.Fprintln(a.out.out, name)
+	}
+	return nil
+}
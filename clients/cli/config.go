@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// Config is read from the config file, by default ~/.config/appened/config.json.
+// APPENED_URL and APPENED_TOKEN override the file, and flags override both.
+type Config struct {
+	URL        string `json:"url"`        // Server's URL, e.g. https://appened.home:8081 or unix:///run/appened/appened.sock
+	Token      string `json:"token"`      // Bearer token, may be empty when using a client certificate
+	CAFile     string `json:"caFile"`     // CA that signed the server's certificate, if it is not publicly trusted
+	ClientCert string `json:"clientCert"` // Client certificate to authenticate with instead of the token
+	ClientKey  string `json:"clientKey"`  // Key for ClientCert
+	Output     string `json:"output"`     // Default output mode, table or json
+}
+
+// defaultConfigPath returns where the config file is kept
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "appened.json"
+	}
+	return filepath.Join(dir, "appened", "config.json")
+}
+
+// loadConfig reads the config file at path, if there is one, and applies environment overrides
+func loadConfig(path string) (Config, error) {
+	config, err := readConfig(path)
+	if err != nil {
+		return config, err
+	}
+
+	if url := os.Getenv("APPENED_URL"); url != "" {
+		config.URL = url
+	}
+	if token := os.Getenv("APPENED_TOKEN"); token != "" {
+		config.Token = token
+	}
+
+	return config, nil
+}
+
+// readConfig reads the config file at path, if there is one, filling in defaults
+func readConfig(path string) (Config, error) {
+	config := Config{URL: "http://localhost:8081", Output: "table"}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return config, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &config); err != nil {
+			return config, err
+		}
+	}
+
+	return config, nil
+}
+
+// saveConfig writes the config file, readable only by its owner as it holds the token
+func saveConfig(path string, config Config) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(config, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, append(data, '\n'), 0600)
+}
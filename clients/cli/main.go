@@ -0,0 +1,176 @@
+// Command appened is a command-line client for 'Appened, built on the go-sdk.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	appendedGo "github.com/appened/clients/go-sdk"
+)
+
+// app is the state shared by every command
+type app struct {
+	config     Config
+	configPath string
+	client     *appendedGo.Client
+	out        printer
+	in         io.Reader
+	errOut     io.Writer
+}
+
+// command is a subcommand of the CLI
+type command struct {
+	name      string
+	aliases   []string
+	usage     string // Arguments and flags, shown after the name in help
+	summary   string
+	folioArgs bool // Whether the first argument is a folio name, so it can be completed
+	hidden    bool // Left out of help and completion
+	run       func(a *app, args []string) error
+}
+
+// commands are every subcommand, in the order they are listed in help
+var commands []*command
+
+func init() {
+	commands = []*command{
+		{name: "folios", aliases: []string{"ls"}, summary: "List folios", run: listFolios},
+		{name: "create", usage: "<folio>", summary: "Create a folio", run: createFolio},
+		{name: "delete", aliases: []string{"rm"}, usage: "[-y] <folio>", summary: "Delete a folio", folioArgs: true, run: deleteFolio},
+		{name: "notes", aliases: []string{"ln"}, usage: "[-done | -undone] <folio>", summary: "List a folio's notes", folioArgs: true, run: listNotes},
+		{name: "append", aliases: []string{"a", "add"}, usage: "<folio> [text...]", summary: "Append a note, or one note per line of stdin if there is no text", folioArgs: true, run: appendNotes},
+		{name: "edit", usage: "<folio> <#> <text...>", summary: "Change the text of a note", folioArgs: true, run: editNote},
+		{name: "done", aliases: []string{"dn"}, usage: "<folio> <#>...", summary: "Toggle done on notes", folioArgs: true, run: toggleDone},
+		{name: "undo", aliases: []string{"u"}, usage: "<folio>", summary: "Undo the last change to a folio", folioArgs: true, run: undo},
+		{name: "redo", usage: "<folio>", summary: "Redo the last undone change to a folio", folioArgs: true, run: redo},
+		{name: "search", aliases: []string{"find"}, usage: "[-folio <folio>] [-done | -undone] <text>", summary: "Find notes containing text, ignoring case", run: search},
+		{name: "export", usage: "[-format json|csv|markdown] [folio...]", summary: "Write folios and their notes to stdout, every folio by default", folioArgs: true, run: export},
+		{name: "config", usage: "[-url <url>] [-token <token>] [-ca <file>] [-cert <file> -key <file>] [-output table|json]", summary: "Save settings to the config file and show them", run: configure},
+		{name: "completion", usage: "bash|zsh|fish", summary: "Print a shell completion script", run: completion},
+		{name: "help", usage: "[command]", summary: "Show help", run: help},
+		{name: "__folios", hidden: true, run: completeFolios},
+	}
+}
+
+// lookup returns the command named or aliased name
+func lookup(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
+		}
+		for _, alias := range cmd.aliases {
+			if alias == name {
+				return cmd
+			}
+		}
+	}
+	return nil
+}
+
+func main() {
+	a := &app{in: os.Stdin, errOut: os.Stderr}
+
+	flags := flag.NewFlagSet("appened", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	flags.StringVar(&a.configPath, "config", defaultConfigPath(), "")
+	url := flags.String("url", "", "")
+	token := flags.String("token", "", "")
+	output := flags.String("o", "", "")
+	if err := flags.Parse(os.Args[1:]); err != nil {
+		fatal(err)
+	}
+
+	config, err := loadConfig(a.configPath)
+	if err != nil {
+		fatal(fmt.Errorf("Reading %v: %w", a.configPath, err))
+	}
+	if *url != "" {
+		config.URL = *url
+	}
+	if *token != "" {
+		config.Token = *token
+	}
+	if *output != "" {
+		config.Output = *output
+	}
+	if config.Output != "table" && config.Output != "json" {
+		fatal(fmt.Errorf("Unknown output %q, must be table or json", config.Output))
+	}
+	a.config = config
+	a.out = printer{os.Stdout, config.Output == "json"}
+
+	options := []appendedGo.Option{}
+	if config.CAFile != "" {
+		options = append(options, appendedGo.WithCAFile(config.CAFile))
+	}
+	if config.ClientCert != "" {
+		options = append(options, appendedGo.WithClientCert(config.ClientCert, config.ClientKey))
+	}
+	a.client = appendedGo.New(config.Token, config.URL, options...)
+
+	args := flags.Args()
+	if len(args) == 0 {
+		args = []string{"help"}
+	}
+
+	cmd := lookup(args[0])
+	if cmd == nil {
+		fatal(fmt.Errorf("Unknown command %q, run appened help", args[0]))
+	}
+	if err := cmd.run(a, args[1:]); err != nil {
+		fatal(err)
+	}
+}
+
+// fatal prints err and exits
+func fatal(err error) {
+	fmt.Fprintf(os.Stderr, "appened: %v\n", err)
+	os.Exit(1)
+}
+
+// help prints every command, or the usage of one
+func help(a *app, args []string) error {
+	if len(args) > 0 {
+		cmd := lookup(args[0])
+		if cmd == nil {
+			return fmt.Errorf("Unknown command %q", args[0])
+		}
+		fmt.Fprintf(a.out.out, "usage: appened %v %v\n\n%v\n", cmd.name, cmd.usage, cmd.summary)
+		if len(cmd.aliases) > 0 {
+			fmt.Fprintf(a.out.out, "\naliases: %v\n", strings.Join(cmd.aliases, ", "))
+		}
+		return nil
+	}
+
+	fmt.Fprintln(a.out.out, "usage: appened [-config <file>] [-url <url>] [-token <token>] [-o table|json] <command> [arguments]")
+	fmt.Fprintln(a.out.out, "\nNotes are numbered from 1, as they are listed.\n\ncommands:")
+	width := 0
+	for _, cmd := range commands {
+		if !cmd.hidden && len(cmd.name) > width {
+			width = len(cmd.name)
+		}
+	}
+	for _, cmd := range commands {
+		if !cmd.hidden {
+			fmt.Fprintf(a.out.out, "  %-*v  %v\n", width, cmd.name, cmd.summary)
+		}
+	}
+	return nil
+}
+
+// commandNames returns the names and aliases of the commands that are not hidden, sorted
+func commandNames() []string {
+	names := []string{}
+	for _, cmd := range commands {
+		if !cmd.hidden {
+			names = append(names, cmd.name)
+			names = append(names, cmd.aliases...)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	appendedGo "github.com/appened/clients/go-sdk"
+)
+
+// printer writes command output as a table for people or JSON for scripts
+type printer struct {
+	out  io.Writer
+	json bool
+}
+
+// value writes v as JSON, or calls table to write it for people
+func (p printer) value(v interface{}, table func(w io.Writer)) error {
+	if p.json {
+		encoder := json.NewEncoder(p.out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(v)
+	}
+
+	w := tabwriter.NewWriter(p.out, 0, 4, 2, ' ', 0)
+	table(w)
+	return w.Flush()
+}
+
+// message writes a confirmation, or {"message": msg} as JSON
+func (p printer) message(msg string) error {
+	return p.value(map[string]string{"message": msg}, func(w io.Writer) {
+		fmt.Fprintln(w, msg)
+	})
+}
+
+// folios writes a list of folios
+func (p printer) folios(folios []appendedGo.FolioSummary) error {
+	return p.value(folios, func(w io.Writer) {
+		fmt.Fprintln(w, "FOLIO\tNOTES")
+		for _, folio := range folios {
+			fmt.Fprintf(w, "%v\t%v\n", folio.Name, folio.NoteCount)
+		}
+	})
+}
+
+// notes writes notes, numbered from 1 as they are in every client
+func (p printer) notes(notes []appendedGo.Note) error {
+	return p.value(notes, func(w io.Writer) {
+		fmt.Fprintln(w, "#\tDONE\tCREATED\tNOTE")
+		for _, n := range notes {
+			fmt.Fprintf(w, "%v\t%v\t%v\t%v\n", n.Index+1, checkmark(n.Done), formatDate(n.DateCreated), n.Text)
+		}
+	})
+}
+
+// matches writes search results
+func (p printer) matches(matches []match) error {
+	return p.value(matches, func(w io.Writer) {
+		fmt.Fprintln(w, "FOLIO\t#\tDONE\tNOTE")
+		for _, m := range matches {
+			fmt.Fprintf(w, "%v\t%v\t%v\t%v\n", m.Folio, m.Note.Index+1, checkmark(m.Note.Done), m.Note.Text)
+		}
+	})
+}
+
+func checkmark(done bool) string {
+	if done {
+		return "✅"
+	}
+	return ""
+}
+
+func formatDate(unix int64) string {
+	if unix == 0 {
+		return ""
+	}
+	return time.Unix(unix, 0).Format("2006-01-02 15:04")
+}
+
+// escapeMarkdown keeps note text from being read as markdown list syntax
+func escapeMarkdown(text string) string {
+	return strings.NewReplacer("\n", " ", "[", `\[`, "]", `\]`).Replace(text)
+}
@@ -0,0 +1,66 @@
+package appendedGo
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Batch collects operations across folios and sends them to the server in a single request.
+// Operations on the same folio are applied together: if one fails, none of them are applied.
+type Batch struct {
+	client *Client
+	ops    []BatchOperation
+}
+
+// Err returns nil if the batched operation was applied, otherwise an error describing why it was not
+func (r BatchResult) Err() error {
+	if r.Status > 201 {
+		if r.Error != "" {
+			return errors.New(r.Error)
+		}
+		return errors.New(http.StatusText(r.Status))
+	}
+	return nil
+}
+
+// NewBatch starts an empty batch of operations
+func (c *Client) NewBatch() *Batch {
+	return &Batch{client: c}
+}
+
+// AddNote queues a note to be appended to a folio
+func (b *Batch) AddNote(folioName string, note string) *Batch {
+	b.ops = append(b.ops, BatchOperation{Op: "append", Folio: folioName, Note: note})
+	return b
+}
+
+// EditNote queues an overwrite of the text of a note
+func (b *Batch) EditNote(folioName string, index int, note string) *Batch {
+	b.ops = append(b.ops, BatchOperation{Op: "edit", Folio: folioName, Index: &index, Note: note})
+	return b
+}
+
+// ToggleDone queues a toggle of the done property on a note
+func (b *Batch) ToggleDone(folioName string, index int) *Batch {
+	b.ops = append(b.ops, BatchOperation{Op: "done", Folio: folioName, Index: &index})
+	return b
+}
+
+// Len returns the number of queued operations
+func (b *Batch) Len() int {
+	return len(b.ops)
+}
+
+// Send applies the queued operations and returns one result per operation, in the order they were queued
+func (b *Batch) Send() ([]BatchResult, error) {
+	if len(b.ops) == 0 {
+		return nil, errors.New("Batch is empty")
+	}
+
+	resp, err := b.client.V2().ApplyBatch(BatchRequest{b.ops})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Results, nil
+}
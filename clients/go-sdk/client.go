@@ -10,14 +10,21 @@ import (
 	"strings"
 )
 
+// onBehalfOfHeader is the header OnBehalfOf sets
+const onBehalfOfHeader = "X-On-Behalf-Of"
+
 type Client struct {
-	token  string
-	client *http.Client
-	url    string
+	token      string
+	client     *http.Client
+	url        string
+	onBehalfOf string
+	err        error // Set if an option could not be applied, and returned from every request
 }
 
-// Create a new Appended client
-func New(token string, baseURL string) *Client {
+// Create a new Appended client. The base URL is either an http(s) URL or unix:// followed by the path of the
+// server's Unix socket, e.g. unix:///run/appened/appened.sock. The token may be empty if the client authenticates with a client certificate.
+// If an option fails, for example because a certificate file cannot be read, every request returns its error.
+func New(token string, baseURL string, options ...Option) *Client {
 	client := Client{}
 	client.token = token
 	client.client = &http.Client{}
@@ -28,6 +35,27 @@ func New(token string, baseURL string) *Client {
 		client.url = baseURL
 	}
 
+	for _, option := range options {
+		if err := option(&client); err != nil {
+			client.err = err
+			break
+		}
+	}
+
+	// Dial unix:///path/to/socket URLs over the Unix socket, sending requests as if to http://unix
+	if socket := strings.TrimPrefix(client.url, "unix://"); socket != client.url && client.err == nil {
+		client.url = "http://unix"
+		client.err = WithUnixSocket(socket)(&client)
+	}
+
+	return &client
+}
+
+// OnBehalfOf returns a client whose requests say they are made for someone else, such as the
+// phone number that texted a message. The server records this in its audit log.
+func (c *Client) OnBehalfOf(who string) *Client {
+	client := *c
+	client.onBehalfOf = who
 	return &client
 }
 
@@ -117,20 +145,72 @@ func (c *Client) DeleteFolio(folioName string) error {
 	return nil
 }
 
+// Undo reverts the last change made to a folio and returns the name of the change, e.g. append
+func (c *Client) Undo(folioName string) (string, error) {
+	body, err := c.makeRequest("POST", "/folios/"+folioName+"/undo", nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp := struct {
+		Undone string `json:"undone"`
+	}{}
+	if err = json.Unmarshal(body, &resp); err != nil {
+		return "", err
+	}
+
+	return resp.Undone, nil
+}
+
+// Redo reapplies the last change to a folio that was undone and returns the name of the change
+func (c *Client) Redo(folioName string) (string, error) {
+	body, err := c.makeRequest("POST", "/folios/"+folioName+"/redo", nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp := struct {
+		Redone string `json:"redone"`
+	}{}
+	if err = json.Unmarshal(body, &resp); err != nil {
+		return "", err
+	}
+
+	return resp.Redone, nil
+}
+
 func (c *Client) makeRequest(method string, route string, data map[string]string) ([]byte, error) {
 	postData := url.Values{}
 	for key, val := range data {
 		postData.Set(key, val)
 	}
 
-	req, err := http.NewRequest(method, c.url+route, strings.NewReader(postData.Encode()))
+	contentType := ""
+	if data != nil {
+		contentType = "application/x-www-form-urlencoded"
+	}
+
+	return c.do(method, route, strings.NewReader(postData.Encode()), contentType)
+}
+
+func (c *Client) do(method string, route string, body io.Reader, contentType string) ([]byte, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	req, err := http.NewRequest(method, c.url+route, body)
 	if err != nil {
 		return nil, err
 	}
 
-	req.Header.Add("Authorization", "Bearer "+c.token)
-	if data != nil {
-		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	if c.token != "" {
+		req.Header.Add("Authorization", "Bearer "+c.token)
+	}
+	if c.onBehalfOf != "" {
+		req.Header.Add(onBehalfOfHeader, c.onBehalfOf)
+	}
+	if contentType != "" {
+		req.Header.Add("Content-Type", contentType)
 	}
 
 	resp, err := c.client.Do(req)
@@ -139,16 +219,19 @@ func (c *Client) makeRequest(method string, route string, data map[string]string
 	}
 	defer resp.Body.Close()
 
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// Prefer the server's explanation of an error when it gives one
 	if resp.StatusCode > 201 {
 		msg := http.StatusText(resp.StatusCode)
+		if len(respBody) > 0 {
+			msg = string(respBody)
+		}
 		return nil, errors.New(msg)
-
-	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
 	}
 
-	return body, err
+	return respBody, err
 }
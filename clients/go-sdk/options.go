@@ -0,0 +1,118 @@
+package appendedGo
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+)
+
+// Option configures a Client
+type Option func(*Client) error
+
+// WithHTTPClient makes requests with client instead of a default http.Client
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Client) error {
+		c.client = client
+		return nil
+	}
+}
+
+// WithTLSConfig makes requests over TLS using config
+func WithTLSConfig(config *tls.Config) Option {
+	return func(c *Client) error {
+		transport, err := c.transport()
+		if err != nil {
+			return err
+		}
+		transport.TLSClientConfig = config
+		return nil
+	}
+}
+
+// WithCAFile trusts servers whose certificates are signed by a CA in the PEM file at path,
+// e.g. for a server using a self-signed certificate
+func WithCAFile(path string) Option {
+	return func(c *Client) error {
+		pem, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("No certificates found in CA file %v", path)
+		}
+
+		config, err := c.tlsConfig()
+		if err != nil {
+			return err
+		}
+		config.RootCAs = roots
+		return nil
+	}
+}
+
+// WithClientCert presents the certificate in certFile to the server, which can authenticate the client
+// with it instead of a token
+func WithClientCert(certFile string, keyFile string) Option {
+	return func(c *Client) error {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return err
+		}
+
+		config, err := c.tlsConfig()
+		if err != nil {
+			return err
+		}
+		config.Certificates = append(config.Certificates, cert)
+		return nil
+	}
+}
+
+// WithUnixSocket sends every request over the Unix socket at path, whatever host the URL names
+func WithUnixSocket(path string) Option {
+	return func(c *Client) error {
+		transport, err := c.transport()
+		if err != nil {
+			return err
+		}
+
+		dialer := &net.Dialer{}
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "unix", path)
+		}
+		return nil
+	}
+}
+
+// transport returns the client's *http.Transport, giving it its own copy of the default transport if it has none
+func (c *Client) transport() (*http.Transport, error) {
+	if c.client.Transport == nil {
+		c.client.Transport = http.DefaultTransport.(*http.Transport).Clone()
+	}
+
+	transport, ok := c.client.Transport.(*http.Transport)
+	if !ok {
+		return nil, errors.New("TLS options require the http.Client to use an *http.Transport")
+	}
+	return transport, nil
+}
+
+// tlsConfig returns the TLS config of the client's transport, creating it if there is none
+func (c *Client) tlsConfig() (*tls.Config, error) {
+	transport, err := c.transport()
+	if err != nil {
+		return nil, err
+	}
+
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	return transport.TLSClientConfig, nil
+}
@@ -0,0 +1,93 @@
+package appendedGo
+
+//go:generate go run github.com/appened/api/sdkgen -package appendedGo -out v2_gen.go
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// V2Client calls the v2 JSON API. Its methods are generated from the OpenAPI document the server
+// validates requests against, so they take and return the same types the server does.
+type V2Client struct {
+	client *Client
+}
+
+// V2 returns a client for the v2 JSON API
+func (c *Client) V2() *V2Client {
+	return &V2Client{c}
+}
+
+// Error allows a Problem returned by the server to be used as an error
+func (p *Problem) Error() string {
+	if p.Detail != "" {
+		return p.Detail
+	}
+	return p.Title
+}
+
+// call makes a request to the v2 API. body is sent as JSON if it is not nil, and a successful
+// response is decoded into out if it is not nil. Unsuccessful responses are returned as a *Problem.
+func (v *V2Client) call(method string, path string, query url.Values, body interface{}, out interface{}) error {
+	if v.client.err != nil {
+		return v.client.err
+	}
+
+	var reqBody io.Reader
+	if body != nil {
+		jsonData, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(jsonData)
+	}
+
+	route := path
+	if len(query) > 0 {
+		route += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequest(method, v.client.url+route, reqBody)
+	if err != nil {
+		return err
+	}
+
+	if v.client.token != "" {
+		req.Header.Add("Authorization", "Bearer "+v.client.token)
+	}
+	req.Header.Add("Accept", "application/json")
+	if v.client.onBehalfOf != "" {
+		req.Header.Add(onBehalfOfHeader, v.client.onBehalfOf)
+	}
+	if body != nil {
+		req.Header.Add("Content-Type", "application/json")
+	}
+
+	resp, err := v.client.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode >= 300 {
+		problem := &Problem{}
+		if err = json.Unmarshal(respBody, problem); err != nil || problem.Status == 0 {
+			problem = &Problem{Type: "about:blank", Title: http.StatusText(resp.StatusCode), Status: resp.StatusCode}
+		}
+		return problem
+	}
+
+	if out == nil || len(respBody) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(respBody, out)
+}
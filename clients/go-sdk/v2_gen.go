@@ -0,0 +1,222 @@
+// Code generated by api/sdkgen from api/openapi.json. DO NOT EDIT.
+
+package appendedGo
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// BatchOperation is the BatchOperation schema
+type BatchOperation struct {
+	Folio string `json:"folio"`
+	Index *int   `json:"index,omitempty"`
+	Note  string `json:"note,omitempty"`
+	Op    string `json:"op"`
+}
+
+// BatchRequest is the BatchRequest schema
+type BatchRequest struct {
+	Operations []BatchOperation `json:"operations"`
+}
+
+// BatchResponse is the BatchResponse schema
+type BatchResponse struct {
+	Results []BatchResult `json:"results"`
+}
+
+// BatchResult is the BatchResult schema
+type BatchResult struct {
+	Error  string `json:"error,omitempty"`
+	Status int    `json:"status"`
+}
+
+// CreateFolioRequest is the CreateFolioRequest schema
+type CreateFolioRequest struct {
+	Name string `json:"name"`
+}
+
+// Folio is the Folio schema
+type Folio struct {
+	Name  string `json:"name"`
+	Notes []Note `json:"notes"`
+}
+
+// FolioSummary is the FolioSummary schema
+type FolioSummary struct {
+	Name      string `json:"name"`
+	NoteCount int    `json:"noteCount"`
+}
+
+// Note is the Note schema
+type Note struct {
+	DateCreated int64  `json:"dateCreated"`
+	DateDone    int64  `json:"dateDone"`
+	DateEdited  int64  `json:"dateEdited"`
+	Done        bool   `json:"done"`
+	Index       int    `json:"index"`
+	Text        string `json:"text"`
+}
+
+// NoteText is the NoteText schema
+type NoteText struct {
+	Text string `json:"text"`
+}
+
+// Problem is the Problem schema
+type Problem struct {
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Status   int    `json:"status"`
+	Title    string `json:"title"`
+	Type     string `json:"type"`
+}
+
+// SetDoneRequest is the SetDoneRequest schema
+type SetDoneRequest struct {
+	Done bool `json:"done"`
+}
+
+// UndoResult is the UndoResult schema
+type UndoResult struct {
+	Redone string `json:"redone,omitempty"`
+	Undone string `json:"undone,omitempty"`
+}
+
+// ApplyBatch calls POST /v2/batch to apply operations across folios, atomically per folio
+func (v *V2Client) ApplyBatch(body BatchRequest) (*BatchResponse, error) {
+	query := url.Values{}
+	result := &BatchResponse{}
+	if err := v.call("POST", "/v2/batch", query, body, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ListFolios calls GET /v2/folios to list all folios
+func (v *V2Client) ListFolios() ([]FolioSummary, error) {
+	query := url.Values{}
+	result := []FolioSummary{}
+	if err := v.call("GET", "/v2/folios", query, nil, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// CreateFolio calls POST /v2/folios to create a folio
+func (v *V2Client) CreateFolio(body CreateFolioRequest) (*Folio, error) {
+	query := url.Values{}
+	result := &Folio{}
+	if err := v.call("POST", "/v2/folios", query, body, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetFolioParams holds the optional query parameters of GetFolio
+type GetFolioParams struct {
+	// Only return notes whose done property matches
+	Done *bool
+}
+
+// GetFolio calls GET /v2/folios/{name} to get a folio and its notes
+func (v *V2Client) GetFolio(name string, params *GetFolioParams) (*Folio, error) {
+	query := url.Values{}
+	if params != nil {
+		if params.Done != nil {
+			query.Set("done", fmt.Sprint(*params.Done))
+		}
+	}
+	result := &Folio{}
+	if err := v.call("GET", fmt.Sprintf("/v2/folios/%v", url.PathEscape(name)), query, nil, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// DeleteFolio calls DELETE /v2/folios/{name} to delete a folio
+func (v *V2Client) DeleteFolio(name string) error {
+	query := url.Values{}
+	return v.call("DELETE", fmt.Sprintf("/v2/folios/%v", url.PathEscape(name)), query, nil, nil)
+}
+
+// ListNotesParams holds the optional query parameters of ListNotes
+type ListNotesParams struct {
+	// Only return notes whose done property matches
+	Done *bool
+}
+
+// ListNotes calls GET /v2/folios/{name}/notes to list a folio's notes
+func (v *V2Client) ListNotes(name string, params *ListNotesParams) ([]Note, error) {
+	query := url.Values{}
+	if params != nil {
+		if params.Done != nil {
+			query.Set("done", fmt.Sprint(*params.Done))
+		}
+	}
+	result := []Note{}
+	if err := v.call("GET", fmt.Sprintf("/v2/folios/%v/notes", url.PathEscape(name)), query, nil, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// AppendNote calls POST /v2/folios/{name}/notes to append a note to a folio
+func (v *V2Client) AppendNote(name string, body NoteText) (*Note, error) {
+	query := url.Values{}
+	result := &Note{}
+	if err := v.call("POST", fmt.Sprintf("/v2/folios/%v/notes", url.PathEscape(name)), query, body, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetNote calls GET /v2/folios/{name}/notes/{index} to get a note
+func (v *V2Client) GetNote(name string, index int) (*Note, error) {
+	query := url.Values{}
+	result := &Note{}
+	if err := v.call("GET", fmt.Sprintf("/v2/folios/%v/notes/%v", url.PathEscape(name), index), query, nil, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// EditNote calls PATCH /v2/folios/{name}/notes/{index} to edit the text of a note
+func (v *V2Client) EditNote(name string, index int, body NoteText) (*Note, error) {
+	query := url.Values{}
+	result := &Note{}
+	if err := v.call("PATCH", fmt.Sprintf("/v2/folios/%v/notes/%v", url.PathEscape(name), index), query, body, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// SetNoteDone calls POST /v2/folios/{name}/notes/{index}/done to mark a note as done or not done
+func (v *V2Client) SetNoteDone(name string, index int, body SetDoneRequest) (*Note, error) {
+	query := url.Values{}
+	result := &Note{}
+	if err := v.call("POST", fmt.Sprintf("/v2/folios/%v/notes/%v/done", url.PathEscape(name), index), query, body, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// RedoFolio calls POST /v2/folios/{name}/redo to redo the last undone change to a folio
+func (v *V2Client) RedoFolio(name string) (*UndoResult, error) {
+	query := url.Values{}
+	result := &UndoResult{}
+	if err := v.call("POST", fmt.Sprintf("/v2/folios/%v/redo", url.PathEscape(name)), query, nil, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// UndoFolio calls POST /v2/folios/{name}/undo to undo the last change to a folio
+func (v *V2Client) UndoFolio(name string) (*UndoResult, error) {
+	query := url.Values{}
+	result := &UndoResult{}
+	if err := v.call("POST", fmt.Sprintf("/v2/folios/%v/undo", url.PathEscape(name)), query, nil, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
+)
+
+const sidebarWidth = 24
+
+var (
+	styleDefault  = tcell.StyleDefault
+	styleTitle    = tcell.StyleDefault.Reverse(true)
+	styleSelected = tcell.StyleDefault.Reverse(true)
+	styleInactive = tcell.StyleDefault.Underline(true)
+	styleDone     = tcell.StyleDefault.Dim(true)
+	styleHint     = tcell.StyleDefault.Dim(true)
+)
+
+// draw redraws the whole screen:
+//
+//	title bar
+//	folios | notes
+//	input line or status
+//	key hints
+func (u *ui) draw() {
+	u.screen.Clear()
+	width, height := u.screen.Size()
+	if height < 5 {
+		u.screen.Show()
+		return
+	}
+
+	title := fmt.Sprintf(" 'Appened  %v", u.url)
+	if !u.refreshed.IsZero() {
+		title += "  refreshed " + u.refreshed.Format("15:04:05")
+	}
+	fill(u.screen, 0, 0, width, styleTitle)
+	put(u.screen, 0, 0, width, title, styleTitle)
+
+	u.drawFolios(1, height-3)
+	for y := 1; y < height-2; y++ {
+		u.screen.SetContent(sidebarWidth, y, tcell.RuneVLine, nil, styleDefault)
+	}
+	u.drawNotes(sidebarWidth+2, 1, width-sidebarWidth-2, height-3)
+
+	u.drawInput(height-2, width)
+	put(u.screen, 0, height-1, width, u.hints(), styleHint)
+	u.screen.Show()
+}
+
+func (u *ui) drawFolios(top int, rows int) {
+	if len(u.folios) == 0 {
+		put(u.screen, 1, top, sidebarWidth-1, "No folios yet", styleHint)
+		return
+	}
+
+	first := 0
+	if u.folio >= rows {
+		first = u.folio - rows + 1
+	}
+	for i := first; i < len(u.folios) && i-first < rows; i++ {
+		folio := u.folios[i]
+		style := styleDefault
+		if i == u.folio {
+			style = styleInactive
+			if u.sidebar {
+				style = styleSelected
+			}
+		}
+		line := fmt.Sprintf(" %-*v%3v ", sidebarWidth-6, truncate(folio.Name, sidebarWidth-6), folio.NoteCount)
+		put(u.screen, 0, top+i-first, sidebarWidth, line, style)
+	}
+}
+
+func (u *ui) drawNotes(left int, top int, width int, rows int) {
+	heading := u.folioName()
+	if u.filter != showAll {
+		heading += "  [" + u.filter.String() + "]"
+	}
+	if u.search != "" && u.mode != modeSearch {
+		heading += fmt.Sprintf("  /%v", u.search)
+	}
+	put(u.screen, left, top, width, heading, styleDefault.Bold(true))
+	top, rows = top+1, rows-1
+
+	shown := u.shown()
+	if len(shown) == 0 {
+		if u.folioName() != "" {
+			put(u.screen, left, top, width, "No notes, press a to append one", styleHint)
+		}
+		return
+	}
+
+	// Scroll to keep the selected note on screen
+	if u.note < u.top {
+		u.top = u.note
+	}
+	if u.note >= u.top+rows {
+		u.top = u.note - rows + 1
+	}
+
+	for i := u.top; i < len(shown) && i-u.top < rows; i++ {
+		n := shown[i]
+		style := styleDefault
+		if n.Done {
+			style = styleDone
+		}
+		if i == u.note && !u.sidebar {
+			style = styleSelected
+		}
+		box := "[ ]"
+		if n.Done {
+			box = "[x]"
+		}
+		line := fmt.Sprintf("%3v %v %v", n.Index+1, box, n.Text)
+		if i == u.note && !u.sidebar {
+			fill(u.screen, left, top+i-u.top, width, style)
+		}
+		put(u.screen, left, top+i-u.top, width, line, style)
+	}
+}
+
+func (u *ui) drawInput(y int, width int) {
+	label := map[mode]string{modeAppend: "Append: ", modeSearch: "Search: "}[u.mode]
+	if u.mode == modeEdit {
+		if n, ok := u.selected(); ok {
+			label = fmt.Sprintf("Edit %v: ", n.Index+1)
+		}
+	}
+	if u.mode == modeNormal {
+		u.screen.HideCursor()
+		put(u.screen, 0, y, width, u.status, styleDefault)
+		return
+	}
+
+	// Scroll long input so the cursor stays on screen
+	x := put(u.screen, 0, y, width, label, styleDefault.Bold(true))
+	first := 0
+	for first < u.cursor && runewidth.StringWidth(string(u.input[first:u.cursor])) >= width-x {
+		first++
+	}
+	put(u.screen, x, y, width-x, string(u.input[first:]), styleDefault)
+	u.screen.ShowCursor(x+runewidth.StringWidth(string(u.input[first:u.cursor])), y)
+}
+
+func (u *ui) hints() string {
+	switch u.mode {
+	case modeNormal:
+		return " j/k move  tab folios/notes  space done  a append  e edit  / search  f filter  u undo  U redo  r refresh  q quit"
+	case modeSearch:
+		return " enter keep search  esc cancel"
+	}
+	return " enter save  esc cancel"
+}
+
+// put writes s at x, y, cut off after width columns, and returns the column after it
+func put(screen tcell.Screen, x int, y int, width int, s string, style tcell.Style) int {
+	end := x + width
+	for _, r := range s {
+		w := runewidth.RuneWidth(r)
+		if r == '\n' || r == '\t' {
+			r, w = ' ', 1
+		}
+		if w == 0 {
+			continue
+		}
+		if x+w > end {
+			break
+		}
+		screen.SetContent(x, y, r, nil, style)
+		x += w
+	}
+	return x
+}
+
+// fill sets the style of a row of cells, for highlighting the whole width of a selected line
+func fill(screen tcell.Screen, x int, y int, width int, style tcell.Style) {
+	for i := 0; i < width; i++ {
+		screen.SetContent(x+i, y, ' ', nil, style)
+	}
+}
+
+// truncate shortens s to fit within width columns, marking it with an ellipsis
+func truncate(s string, width int) string {
+	if runewidth.StringWidth(s) <= width {
+		return s
+	}
+	return runewidth.Truncate(s, width, "…")
+}
@@ -0,0 +1,66 @@
+// Command appened-tui is a full-screen terminal client for 'Appened, built on the go-sdk.
+// It draws with tcell, so it works in any terminal, including over SSH.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	appendedGo "github.com/appened/clients/go-sdk"
+	"github.com/gdamore/tcell/v2"
+)
+
+func main() {
+	url := flag.String("url", envOr("APPENED_URL", "http://localhost:8081"), "Server's URL, e.g. https://appened.home:8081 or unix:///run/appened/appened.sock")
+	token := flag.String("token", os.Getenv("APPENED_TOKEN"), "Bearer token, may be empty when using a client certificate")
+	caFile := flag.String("ca", "", "CA that signed the server's certificate, if it is not publicly trusted")
+	certFile := flag.String("cert", "", "Client certificate to authenticate with instead of the token")
+	keyFile := flag.String("key", "", "Key for -cert")
+	refresh := flag.Duration("refresh", 5*time.Second, "How often to fetch changes made by other clients")
+	flag.Parse()
+
+	options := []appendedGo.Option{}
+	if *caFile != "" {
+		options = append(options, appendedGo.WithCAFile(*caFile))
+	}
+	if *certFile != "" {
+		options = append(options, appendedGo.WithClientCert(*certFile, *keyFile))
+	}
+	client := appendedGo.New(*token, *url, options...)
+
+	// Fail before taking over the terminal if the server can't be reached
+	if _, err := client.V2().ListFolios(); err != nil {
+		fatal(fmt.Errorf("Connecting to %v: %w", *url, err))
+	}
+
+	screen, err := tcell.NewScreen()
+	if err != nil {
+		fatal(err)
+	}
+	if err = screen.Init(); err != nil {
+		fatal(err)
+	}
+
+	u := newUI(screen, client, *url)
+	err = u.run(*refresh)
+	screen.Fini()
+	if err != nil {
+		fatal(err)
+	}
+}
+
+// envOr returns the environment variable key, or fallback if it is not set
+func envOr(key string, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// fatal prints err and exits
+func fatal(err error) {
+	fmt.Fprintf(os.Stderr, "appened-tui: %v\n", err)
+	os.Exit(1)
+}
@@ -0,0 +1,402 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	appendedGo "github.com/appened/clients/go-sdk"
+	"github.com/gdamore/tcell/v2"
+)
+
+// mode is what keypresses are currently used for
+type mode int
+
+const (
+	modeNormal mode = iota // Moving around and acting on the selection
+	modeAppend             // Typing a note to append
+	modeEdit               // Typing a note's new text
+	modeSearch             // Typing text to filter notes by
+)
+
+// doneFilter is which notes are shown, cycled with f
+type doneFilter int
+
+const (
+	showAll doneFilter = iota
+	showUndone
+	showDone
+)
+
+func (f doneFilter) String() string {
+	return [...]string{"all", "undone", "done"}[f]
+}
+
+// ui holds everything drawn on screen. It is only touched from the event loop in run, so needs no lock.
+type ui struct {
+	screen tcell.Screen
+	client *appendedGo.Client
+	url    string
+
+	folios []appendedGo.FolioSummary
+	folio  int               // Selected folio in the sidebar
+	notes  []appendedGo.Note // Every note in the selected folio
+	note   int               // Selected note, an index into shown()
+	top    int               // First shown note on screen, for scrolling
+
+	sidebar bool // Whether keys move through folios rather than notes
+	filter  doneFilter
+	search  string
+
+	mode   mode
+	input  []rune
+	cursor int
+
+	status    string // Result of the last action, or an error
+	refreshed time.Time
+}
+
+func newUI(screen tcell.Screen, client *appendedGo.Client, url string) *ui {
+	return &ui{screen: screen, client: client, url: url}
+}
+
+// run draws the screen and handles events until the user quits, fetching changes every interval
+func (u *ui) run(interval time.Duration) error {
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				u.screen.PostEvent(tcell.NewEventInterrupt(nil))
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	u.refresh()
+	for {
+		u.draw()
+		switch ev := u.screen.PollEvent().(type) {
+		case nil:
+			return nil
+		case *tcell.EventResize:
+			u.screen.Sync()
+		case *tcell.EventInterrupt:
+			u.refresh()
+		case *tcell.EventKey:
+			if quit := u.key(ev); quit {
+				return nil
+			}
+		}
+	}
+}
+
+// refresh fetches folios and the selected folio's notes, keeping the selection where it was
+func (u *ui) refresh() {
+	selected := u.folioName()
+
+	folios, err := u.client.V2().ListFolios()
+	if err != nil {
+		u.status = "Refreshing: " + err.Error()
+		return
+	}
+	sort.Slice(folios, func(i, j int) bool { return folios[i].Name < folios[j].Name })
+	u.folios = folios
+
+	u.folio = 0
+	for i, folio := range folios {
+		if folio.Name == selected {
+			u.folio = i
+		}
+	}
+
+	u.notes = nil
+	if name := u.folioName(); name != "" {
+		folio, err := u.client.V2().GetFolio(name, nil)
+		if err != nil {
+			u.status = "Refreshing: " + err.Error()
+			return
+		}
+		u.notes = folio.Notes
+	}
+	u.clampNote()
+	u.refreshed = time.Now()
+}
+
+// folioName returns the name of the selected folio, or "" if there are none
+func (u *ui) folioName() string {
+	if u.folio < len(u.folios) {
+		return u.folios[u.folio].Name
+	}
+	return ""
+}
+
+// shown returns the notes matching the done filter and search
+func (u *ui) shown() []appendedGo.Note {
+	query := strings.ToLower(u.search)
+	if u.mode == modeSearch {
+		query = strings.ToLower(string(u.input))
+	}
+
+	shown := []appendedGo.Note{}
+	for _, n := range u.notes {
+		if (u.filter == showUndone && n.Done) || (u.filter == showDone && !n.Done) {
+			continue
+		}
+		if query != "" && !strings.Contains(strings.ToLower(n.Text), query) {
+			continue
+		}
+		shown = append(shown, n)
+	}
+	return shown
+}
+
+// selected returns the selected note, if any
+func (u *ui) selected() (appendedGo.Note, bool) {
+	shown := u.shown()
+	if u.note < len(shown) {
+		return shown[u.note], true
+	}
+	return appendedGo.Note{}, false
+}
+
+func (u *ui) clampNote() {
+	if n := len(u.shown()); u.note >= n {
+		u.note = n - 1
+	}
+	if u.note < 0 {
+		u.note = 0
+	}
+}
+
+// key handles a keypress, returning true to quit
+func (u *ui) key(ev *tcell.EventKey) bool {
+	if ev.Key() == tcell.KeyCtrlC {
+		return true
+	}
+	if u.mode != modeNormal {
+		u.edit(ev)
+		return false
+	}
+
+	switch ev.Key() {
+	case tcell.KeyTab, tcell.KeyBacktab:
+		u.sidebar = !u.sidebar
+		return false
+	case tcell.KeyUp:
+		u.move(-1)
+		return false
+	case tcell.KeyDown:
+		u.move(1)
+		return false
+	case tcell.KeyPgUp:
+		u.move(-10)
+		return false
+	case tcell.KeyPgDn:
+		u.move(10)
+		return false
+	case tcell.KeyLeft:
+		u.sidebar = true
+		return false
+	case tcell.KeyRight:
+		u.sidebar = false
+		return false
+	case tcell.KeyEnter:
+		if u.sidebar {
+			u.sidebar = false
+		} else {
+			u.toggleDone()
+		}
+		return false
+	case tcell.KeyEscape:
+		u.search = ""
+		u.clampNote()
+		return false
+	case tcell.KeyRune:
+	default:
+		return false
+	}
+
+	switch ev.Rune() {
+	case 'q':
+		return true
+	case 'k':
+		u.move(-1)
+	case 'j':
+		u.move(1)
+	case 'h':
+		u.sidebar = true
+	case 'l':
+		u.sidebar = false
+	case ' ', 'x':
+		u.toggleDone()
+	case 'a':
+		u.prompt(modeAppend, "")
+	case 'e':
+		if n, ok := u.selected(); ok {
+			u.prompt(modeEdit, n.Text)
+		}
+	case '/':
+		u.prompt(modeSearch, u.search)
+	case 'f':
+		u.filter = (u.filter + 1) % 3
+		u.clampNote()
+	case 'u':
+		u.undo(false)
+	case 'U':
+		u.undo(true)
+	case 'r':
+		u.refresh()
+		u.status = "Refreshed"
+	}
+	return false
+}
+
+// move moves the selection in the sidebar or the note list by delta
+func (u *ui) move(delta int) {
+	if !u.sidebar {
+		u.note += delta
+		u.clampNote()
+		return
+	}
+
+	u.folio += delta
+	if u.folio >= len(u.folios) {
+		u.folio = len(u.folios) - 1
+	}
+	if u.folio < 0 {
+		u.folio = 0
+	}
+	u.note, u.top, u.search = 0, 0, ""
+	u.refresh()
+}
+
+// prompt starts typing into the input line, starting from text
+func (u *ui) prompt(m mode, text string) {
+	if m != modeSearch && u.folioName() == "" {
+		u.status = "No folio selected"
+		return
+	}
+	u.mode = m
+	u.input = []rune(text)
+	u.cursor = len(u.input)
+}
+
+// edit handles a keypress while typing into the input line
+func (u *ui) edit(ev *tcell.EventKey) {
+	switch ev.Key() {
+	case tcell.KeyEscape:
+		u.mode = modeNormal
+		u.clampNote()
+	case tcell.KeyEnter:
+		u.submit()
+	case tcell.KeyLeft:
+		if u.cursor > 0 {
+			u.cursor--
+		}
+	case tcell.KeyRight:
+		if u.cursor < len(u.input) {
+			u.cursor++
+		}
+	case tcell.KeyHome, tcell.KeyCtrlA:
+		u.cursor = 0
+	case tcell.KeyEnd, tcell.KeyCtrlE:
+		u.cursor = len(u.input)
+	case tcell.KeyCtrlU:
+		u.input, u.cursor = u.input[u.cursor:], 0
+	case tcell.KeyBackspace, tcell.KeyBackspace2:
+		if u.cursor > 0 {
+			u.input = append(u.input[:u.cursor-1], u.input[u.cursor:]...)
+			u.cursor--
+		}
+	case tcell.KeyDelete:
+		if u.cursor < len(u.input) {
+			u.input = append(u.input[:u.cursor], u.input[u.cursor+1:]...)
+		}
+	case tcell.KeyRune:
+		u.input = append(u.input[:u.cursor], append([]rune{ev.Rune()}, u.input[u.cursor:]...)...)
+		u.cursor++
+	}
+
+	if u.mode == modeSearch {
+		u.note, u.top = 0, 0
+	}
+}
+
+// submit acts on the text typed into the input line
+func (u *ui) submit() {
+	text := strings.TrimSpace(string(u.input))
+	m := u.mode
+	u.mode = modeNormal
+
+	switch m {
+	case modeSearch:
+		u.search = text
+		u.clampNote()
+	case modeAppend:
+		if text == "" {
+			return
+		}
+		n, err := u.client.V2().AppendNote(u.folioName(), appendedGo.NoteText{Text: text})
+		if u.done(err, "Appended note %v", n) {
+			u.filter, u.search = showAll, ""
+			u.note = len(u.shown()) - 1
+		}
+	case modeEdit:
+		selected, ok := u.selected()
+		if !ok || text == "" || text == selected.Text {
+			return
+		}
+		n, err := u.client.V2().EditNote(u.folioName(), selected.Index, appendedGo.NoteText{Text: text})
+		u.done(err, "Edited note %v", n)
+	}
+}
+
+func (u *ui) toggleDone() {
+	selected, ok := u.selected()
+	if !ok {
+		return
+	}
+	n, err := u.client.V2().SetNoteDone(u.folioName(), selected.Index, appendedGo.SetDoneRequest{Done: !selected.Done})
+	u.done(err, "Toggled done on note %v", n)
+}
+
+func (u *ui) undo(redo bool) {
+	name := u.folioName()
+	if name == "" {
+		return
+	}
+
+	if redo {
+		result, err := u.client.V2().RedoFolio(name)
+		if err == nil {
+			u.status = "Redid " + result.Redone
+		}
+		u.done(err, "", nil)
+		return
+	}
+	result, err := u.client.V2().UndoFolio(name)
+	if err == nil {
+		u.status = "Undid " + result.Undone
+	}
+	u.done(err, "", nil)
+}
+
+// done reports the result of a change and refreshes to show it, returning whether it succeeded.
+// format, if not empty, is given the changed note's number.
+func (u *ui) done(err error, format string, n *appendedGo.Note) bool {
+	if err != nil {
+		u.status = err.Error()
+		return false
+	}
+	if format != "" && n != nil {
+		u.status = fmt.Sprintf(format, n.Index+1)
+	}
+	u.refresh()
+	return true
+}
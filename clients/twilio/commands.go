@@ -0,0 +1,418 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	appendedGo "github.com/appened/clients/go-sdk"
+)
+
+// argKind is the kind of value an argument takes
+type argKind int
+
+const (
+	argWord   argKind = iota // A single word
+	argFolio                 // A folio name, which can be left out to use the conversation's folio
+	argNumber                // A note number, counting from 1
+	argText                  // The rest of the message, so it must come last
+)
+
+// arg describes one argument a command takes
+type arg struct {
+	name string
+	kind argKind
+}
+
+// command is something that can be texted, like `a groceries milk`
+type command struct {
+	name    string
+	aliases []string
+	args    []arg
+	help    string
+	changes bool   // Whether the command changes anything, so read-only users may not text it
+	confirm string // Question asked before running the command, given its arguments, if it needs confirming
+	run     func(c *chat, args []string) (string, error)
+}
+
+// chat is a text being answered: who sent it, and what the client remembers about them
+type chat struct {
+	user         *sender
+	conv         *conversation
+	folioOmitted bool // Whether the folio was left out of the text, and taken from the conversation
+}
+
+// usage returns how the command is written, e.g. `dn <folioName> <number>`
+func (cmd *command) usage() string {
+	usage := cmd.name
+	for _, a := range cmd.args {
+		if a.kind == argText {
+			usage += " <" + a.name + "...>"
+		} else {
+			usage += " <" + a.name + ">"
+		}
+	}
+	return usage
+}
+
+// parse checks words against the command's arguments, joining the rest of the message into a text argument.
+// A folio left out is taken from the conversation.
+func (cmd *command) parse(words []string, c *chat) ([]string, error) {
+	if len(cmd.args) > 0 && cmd.args[0].kind == argFolio && cmd.folioOmitted(words, c) {
+		if c.conv.Folio == "" {
+			return nil, fmt.Errorf("Which folio? Text use <folioName> first, or try: %v", cmd.usage())
+		}
+		words = append([]string{c.conv.Folio}, words...)
+		c.folioOmitted = true
+	}
+
+	args := []string{}
+	for i, a := range cmd.args {
+		if i >= len(words) {
+			return nil, fmt.Errorf("Missing %v, try: %v", a.name, cmd.usage())
+		}
+		switch a.kind {
+		case argNumber:
+			if n, err := strconv.Atoi(words[i]); err != nil || n < 1 {
+				return nil, fmt.Errorf("%v is not a note number, try: %v", words[i], cmd.usage())
+			}
+		case argText:
+			args = append(args, strings.Join(words[i:], " "))
+			return args, nil
+		}
+		args = append(args, words[i])
+	}
+
+	if len(words) > len(cmd.args) {
+		return nil, fmt.Errorf("Too many words, try: %v", cmd.usage())
+	}
+	return args, nil
+}
+
+// folioOmitted reports whether words leave out the command's folio. That is clear when there are too few
+// words, but `a milk and eggs` could be appending to a folio named milk, so then the sender's folio names
+// are checked, which are only fetched if they have not been recently.
+func (cmd *command) folioOmitted(words []string, c *chat) bool {
+	if len(words) < len(cmd.args) {
+		return true
+	}
+	if c.conv.Folio == "" || words[0] == c.conv.Folio || cmd.args[len(cmd.args)-1].kind != argText {
+		return false
+	}
+
+	folios, err := c.user.folioNames()
+	if err != nil {
+		return false
+	}
+	for _, folio := range folios {
+		if folio == words[0] {
+			return false
+		}
+	}
+	return true
+}
+
+// commands are every command, in the order help lists them
+var commands []*command
+
+func init() {
+	folio := arg{"folioName", argFolio}
+	commands = []*command{
+		{name: "h", aliases: []string{"help", "?"}, help: "this message", run: help},
+		{name: "lf", aliases: []string{"folios"}, help: "list folios", run: listFolios},
+		{name: "use", args: []arg{{"folioName", argWord}}, help: "use folio when a command leaves it out", run: useFolio},
+		{name: "cf", aliases: []string{"create"}, args: []arg{{"folioName", argWord}}, help: "create folio", changes: true, run: createFolio},
+		{name: "df", aliases: []string{"delete"}, args: []arg{folio}, help: "delete folio", changes: true, confirm: "Delete folio %v and all of its notes?", run: deleteFolio},
+		{name: "ln", aliases: []string{"notes"}, args: []arg{folio}, help: "list notes in folio", run: listNotes(false, "No unfinished notes!")},
+		{name: "lna", aliases: []string{"all"}, args: []arg{folio}, help: "list all notes in folio, including done", run: listNotes(true, "No notes yet!")},
+		{name: "lnd", aliases: []string{"finished"}, args: []arg{folio}, help: "list all done notes in folio", run: listDoneNotes},
+		{name: "dn", aliases: []string{"done"}, args: []arg{folio, {"number", argNumber}}, help: "Toggle done on note at number", changes: true, run: toggleDone},
+		{name: "a", aliases: []string{"add", "append"}, args: []arg{folio, {"msg", argText}}, help: "append note to folio", changes: true, run: appendNote},
+		{name: "u", aliases: []string{"undo"}, args: []arg{folio}, help: "undo last change to folio", changes: true, run: undo},
+		{name: "more", aliases: []string{"next"}, help: "next page of a long reply", run: more},
+	}
+}
+
+// lookup returns the command named or aliased name, ignoring case
+func lookup(name string) *command {
+	name = strings.ToLower(name)
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
+		}
+		for _, alias := range cmd.aliases {
+			if alias == name {
+				return cmd
+			}
+		}
+	}
+	return nil
+}
+
+// messageResponse runs the command texted in msg for a user, returning the first page of the reply. conv is
+// updated with what should be remembered for their next text, including the reply's other pages.
+func messageResponse(msg string, user *sender, conv *conversation, pageSize int) (string, error) {
+	reply, err := respond(msg, user, conv)
+	if err != nil {
+		// more would otherwise carry on with the pages of a reply from before the error
+		conv.Pages = nil
+		return reply, err
+	}
+	if lookup(firstWord(msg)) == lookup("more") {
+		return reply, nil
+	}
+
+	pages := paginate(reply, pageSize)
+	conv.Pages = pages[1:]
+	return pages[0], nil
+}
+
+// firstWord returns the first word of msg, if any
+func firstWord(msg string) string {
+	if words := strings.Fields(msg); len(words) > 0 {
+		return words[0]
+	}
+	return ""
+}
+
+// respond runs the command texted in msg, returning the whole reply
+func respond(msg string, user *sender, conv *conversation) (string, error) {
+	words := strings.Fields(msg)
+	if len(words) == 0 {
+		return "", errors.New("Empty text message received")
+	}
+	c := &chat{user: user, conv: conv}
+
+	// Answer a question asked by the last text. Anything other than yes cancels the command asked about.
+	answer := strings.ToLower(words[0])
+	if p := conv.Pending; p != nil {
+		conv.Pending = nil
+		switch {
+		case (answer == "yes" || answer == "y") && time.Now().Before(p.Expires) && lookup(p.Command) != nil:
+			return lookup(p.Command).run(c, p.Args)
+		case answer == "no" || answer == "n":
+			return "Cancelled", nil
+		}
+	}
+	if answer == "yes" || answer == "y" || answer == "no" || answer == "n" {
+		return "", errors.New("Nothing to confirm")
+	}
+
+	cmd := lookup(words[0])
+	if cmd == nil {
+		if suggestion := suggest(words[0]); suggestion != "" {
+			return "", fmt.Errorf("Invalid command %v, did you mean %v? Text h for help", words[0], suggestion)
+		}
+		return "", fmt.Errorf("Invalid command %v, text h for help", words[0])
+	}
+
+	if cmd.changes && user.ReadOnly {
+		return "", fmt.Errorf("Your number can only read folios, so it can't use %v", cmd.name)
+	}
+
+	args, err := cmd.parse(words[1:], c)
+	if err != nil {
+		return "", err
+	}
+
+	if cmd.confirm != "" {
+		conv.Pending = &pending{cmd.name, args, time.Now().Add(confirmFor)}
+		question := fmt.Sprintf(cmd.confirm, strings.Join(args, " "))
+		return question + " Text yes to confirm", nil
+	}
+	return cmd.run(c, args)
+}
+
+// suggest returns the command name or alias closest to a mistyped one, if any is close enough
+func suggest(typed string) string {
+	typed = strings.ToLower(typed)
+	best, bestDistance := "", 3
+	for _, cmd := range commands {
+		for _, name := range append([]string{cmd.name}, cmd.aliases...) {
+			// Only suggest short names for near misses, as most of them are two letters
+			limit := 2
+			if len(name) <= 2 {
+				limit = 1
+			}
+			if d := distance(typed, name); d <= limit && d < bestDistance {
+				best, bestDistance = name, d
+			}
+		}
+	}
+	return best
+}
+
+// distance returns the Levenshtein distance between a and b
+func distance(a string, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		cur := make([]int, len(rb)+1)
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(rb)]
+}
+
+func help(c *chat, args []string) (string, error) {
+	lines := []string{}
+	for _, cmd := range commands {
+		line := cmd.usage() + ": " + cmd.help
+		if len(cmd.aliases) > 0 {
+			line += " (or " + strings.Join(cmd.aliases, ", ") + ")"
+		}
+		lines = append(lines, line)
+	}
+	lines = append(lines, "Leave out folioName to use the folio last used or listed")
+	return strings.Join(lines, "\n"), nil
+}
+
+// more replies with the next page of the last reply
+func more(c *chat, args []string) (string, error) {
+	if len(c.conv.Pages) == 0 {
+		return "", errors.New("Nothing more to show")
+	}
+	page := c.conv.Pages[0]
+	c.conv.Pages = c.conv.Pages[1:]
+	return page, nil
+}
+
+func listFolios(c *chat, args []string) (string, error) {
+	folioNames, err := c.user.client.GetFolios()
+	if err != nil {
+		return "", err
+	}
+	c.user.rememberFolios(folioNames)
+	if len(folioNames) == 0 {
+		return "No folios yet!", nil
+	}
+	return strings.Join(folioNames, "\n"), nil
+}
+
+func useFolio(c *chat, args []string) (string, error) {
+	folioNames, err := c.user.client.GetFolios()
+	if err != nil {
+		return "", err
+	}
+	c.user.rememberFolios(folioNames)
+	for _, name := range folioNames {
+		if name == args[0] {
+			c.conv.Folio, c.conv.Listed = name, nil
+			return "Using folio " + name, nil
+		}
+	}
+	return "", fmt.Errorf("There is no folio named %v", args[0])
+}
+
+func createFolio(c *chat, args []string) (string, error) {
+	if err := c.user.client.CreateFolio(args[0]); err != nil {
+		return "", err
+	}
+	c.user.rememberFolios(nil)
+	return "Created folio with name " + args[0], nil
+}
+
+func deleteFolio(c *chat, args []string) (string, error) {
+	if err := c.user.client.DeleteFolio(args[0]); err != nil {
+		return "", err
+	}
+	c.user.rememberFolios(nil)
+	if c.conv.Folio == args[0] {
+		c.conv.Folio, c.conv.Listed = "", nil
+	}
+	return "Deleted folio", nil
+}
+
+// listNotes lists the notes in a folio that are not done, or every note if all is set
+func listNotes(all bool, none string) func(c *chat, args []string) (string, error) {
+	return func(c *chat, args []string) (string, error) {
+		var done *bool
+		if !all {
+			done = new(bool)
+		}
+		return c.list(args[0], done, none)
+	}
+}
+
+func listDoneNotes(c *chat, args []string) (string, error) {
+	done := true
+	return c.list(args[0], &done, "Nothing finished yet!")
+}
+
+// list replies with a folio's notes, filtered by done if it is not nil, and remembers them so
+// the folio can be left out of the next command
+func (c *chat) list(folio string, done *bool, none string) (string, error) {
+	notes, err := c.user.client.V2().ListNotes(folio, &appendedGo.ListNotesParams{Done: done})
+	if err != nil {
+		return "", err
+	}
+
+	c.conv.Folio, c.conv.Listed = folio, []int{}
+	lines := []string{}
+	for _, n := range notes {
+		line := fmt.Sprintf("%v. %v", n.Index+1, n.Text)
+		if n.Done {
+			line += " ✅"
+		}
+		lines = append(lines, line)
+		c.conv.Listed = append(c.conv.Listed, n.Index+1)
+	}
+	if len(lines) == 0 {
+		return none, nil
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func toggleDone(c *chat, args []string) (string, error) {
+	number, _ := strconv.Atoi(args[1])
+
+	// Numbers refer to the last list when the folio is left out, so catch one that wasn't in it
+	if c.folioOmitted && c.conv.Listed != nil && !containsNumber(c.conv.Listed, number) {
+		return "", fmt.Errorf("Note %v wasn't in the last list of %v, text ln to list its notes", number, args[0])
+	}
+
+	if err := c.user.client.ToggleDone(args[0], number-1); err != nil {
+		return "", err
+	}
+	return "Toggled done", nil
+}
+
+func containsNumber(numbers []int, number int) bool {
+	for _, n := range numbers {
+		if n == number {
+			return true
+		}
+	}
+	return false
+}
+
+func appendNote(c *chat, args []string) (string, error) {
+	if err := c.user.client.AddNote(args[0], args[1]); err != nil {
+		return "", err
+	}
+	return "Appended to " + args[0], nil
+}
+
+func undo(c *chat, args []string) (string, error) {
+	op, err := c.user.client.Undo(args[0])
+	if err != nil {
+		return "", err
+	}
+	if op == "delete" {
+		c.user.rememberFolios(nil)
+	}
+	return "Undid " + op, nil
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// helpText is the reply to h, listing every command in the registry
+const helpText = `h: this message (or help, ?)
+lf: list folios (or folios)
+use <folioName>: use folio when a command leaves it out
+cf <folioName>: create folio (or create)
+df <folioName>: delete folio (or delete)
+ln <folioName>: list notes in folio (or notes)
+lna <folioName>: list all notes in folio, including done (or all)
+lnd <folioName>: list all done notes in folio (or finished)
+dn <folioName> <number>: Toggle done on note at number (or done)
+a <folioName> <msg...>: append note to folio (or add, append)
+u <folioName>: undo last change to folio (or undo)
+more: next page of a long reply (or next)
+Leave out folioName to use the folio last used or listed`
+
+// groceries texts set up a folio with one note done and one not
+var groceries = []string{"cf groceries", "a groceries milk", "a groceries eggs", "dn groceries 1"}
+
+func TestCommands(t *testing.T) {
+	tests := []struct {
+		name     string
+		setup    []string // Texted first, each must succeed
+		text     string
+		want     string // The reply, or the error replied with
+		readOnly bool
+		pageSize int // Defaults to one that fits every reply on a page
+	}{
+		{name: "h", text: "h", want: helpText},
+		{name: "help", text: "help", want: helpText},
+		{name: "?", text: "?", want: helpText},
+		{name: "uppercase", text: "HELP", want: helpText},
+
+		{name: "lf", setup: []string{"cf groceries"}, text: "lf", want: "groceries"},
+		{name: "folios", text: "folios", want: "No folios yet!"},
+		{name: "lf with arguments", text: "lf groceries", want: "Too many words, try: lf"},
+
+		{name: "use", setup: []string{"cf groceries"}, text: "use groceries", want: "Using folio groceries"},
+		{name: "use missing folio", text: "use groceries", want: "There is no folio named groceries"},
+
+		{name: "cf", text: "cf groceries", want: "Created folio with name groceries"},
+		{name: "create", text: "create groceries", want: "Created folio with name groceries"},
+		{name: "cf without name", text: "cf", want: "Missing folioName, try: cf <folioName>"},
+		{name: "cf read-only", text: "cf groceries", readOnly: true, want: "Your number can only read folios, so it can't use cf"},
+
+		{name: "df", setup: []string{"cf groceries"}, text: "df groceries", want: "Delete folio groceries and all of its notes? Text yes to confirm"},
+		{name: "delete", setup: []string{"cf groceries", "use groceries"}, text: "delete", want: "Delete folio groceries and all of its notes? Text yes to confirm"},
+		{name: "df confirmed", setup: []string{"cf groceries", "df groceries"}, text: "yes", want: "Deleted folio"},
+		{name: "df cancelled", setup: []string{"cf groceries", "df groceries"}, text: "no", want: "Cancelled"},
+		{name: "nothing to confirm", text: "yes", want: "Nothing to confirm"},
+
+		{name: "ln", setup: groceries, text: "ln groceries", want: "2. eggs"},
+		{name: "notes", setup: append(groceries, "use groceries"), text: "notes", want: "2. eggs"},
+		{name: "ln without folio", text: "ln", want: "Which folio? Text use <folioName> first, or try: ln <folioName>"},
+		{name: "ln empty", setup: []string{"cf groceries"}, text: "ln groceries", want: "No unfinished notes!"},
+
+		{name: "lna", setup: groceries, text: "lna groceries", want: "1. milk ✅\n2. eggs"},
+		{name: "all", setup: []string{"cf groceries", "use groceries"}, text: "all", want: "No notes yet!"},
+
+		{name: "lnd", setup: groceries, text: "lnd groceries", want: "1. milk ✅"},
+		{name: "finished", setup: []string{"cf groceries", "use groceries"}, text: "finished", want: "Nothing finished yet!"},
+
+		{name: "dn", setup: groceries, text: "dn groceries 2", want: "Toggled done"},
+		{name: "done", setup: append(groceries, "ln groceries"), text: "done 2", want: "Toggled done"},
+		{name: "dn not listed", setup: append(groceries, "ln groceries"), text: "dn 1", want: "Note 1 wasn't in the last list of groceries, text ln to list its notes"},
+		{name: "dn not a number", setup: groceries, text: "dn groceries first", want: "first is not a note number, try: dn <folioName> <number>"},
+
+		{name: "a", setup: []string{"cf groceries"}, text: "a groceries milk", want: "Appended to groceries"},
+		{name: "add", setup: []string{"cf groceries", "use groceries"}, text: "add oat milk", want: "Appended to groceries"},
+		{name: "append", setup: []string{"cf groceries", "cf chores", "use groceries"}, text: "append chores sweep", want: "Appended to chores"},
+
+		{name: "u", setup: []string{"cf groceries", "a groceries milk"}, text: "u groceries", want: "Undid append"},
+		{name: "undo", setup: []string{"cf groceries", "use groceries", "a milk"}, text: "undo", want: "Undid append"},
+
+		{name: "more", setup: []string{"h"}, pageSize: 120, text: "more", want: paginate(helpText, 120)[1]},
+		{name: "next", setup: []string{"h", "more"}, pageSize: 120, text: "next", want: paginate(helpText, 120)[2]},
+		{name: "more without pages", text: "more", want: "Nothing more to show"},
+
+		{name: "typo", text: "lnn groceries", want: "Invalid command lnn, did you mean ln? Text h for help"},
+		{name: "unknown", text: "groceries", want: "Invalid command groceries, text h for help"},
+		{name: "empty", text: " ", want: "Empty text message received"},
+	}
+
+	// Every command and alias in the registry needs a row
+	texted := map[string]bool{}
+	for _, test := range tests {
+		texted[strings.ToLower(firstWord(test.text))] = true
+	}
+	for _, cmd := range commands {
+		for _, name := range append([]string{cmd.name}, cmd.aliases...) {
+			if !texted[name] {
+				t.Errorf("No test texts %v", name)
+			}
+		}
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := testConfig(testAPI(t))
+			user := senders(config)[testNumber]
+			pageSize := test.pageSize
+			if pageSize == 0 {
+				pageSize = 1000
+			}
+
+			conv := conversation{}
+			for _, msg := range test.setup {
+				if _, err := messageResponse(msg, user, &conv, pageSize); err != nil {
+					t.Fatalf("Setting up with %q: %v", msg, err)
+				}
+			}
+
+			user.ReadOnly = test.readOnly
+			got, err := messageResponse(test.text, user, &conv, pageSize)
+			if err != nil {
+				got = err.Error()
+			}
+			if got != test.want {
+				t.Errorf("Texting %q got:\n%v\nwant:\n%v", test.text, got, test.want)
+			}
+		})
+	}
+}
+
+func TestErrorForgetsPages(t *testing.T) {
+	user := senders(testConfig(testAPI(t)))[testNumber]
+	conv := conversation{}
+
+	if _, err := messageResponse("h", user, &conv, 120); err != nil {
+		t.Fatal(err)
+	}
+	if len(conv.Pages) == 0 {
+		t.Fatal("Help fits on one page, so there is nothing to forget")
+	}
+
+	if _, err := messageResponse("lnn", user, &conv, 120); err == nil {
+		t.Fatal("Texting an unknown command succeeded")
+	}
+	if conv.Pages != nil {
+		t.Errorf("Pages from before the error were kept: %q", conv.Pages)
+	}
+}
+
+func TestHelp(t *testing.T) {
+	got, err := help(&chat{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != helpText {
+		t.Errorf("Got help:\n%v\nwant:\n%v", got, helpText)
+	}
+
+	// Every command and alias is listed
+	for _, cmd := range commands {
+		if !strings.Contains(got, cmd.usage()+": "+cmd.help) {
+			t.Errorf("Help does not describe %v", cmd.name)
+		}
+		for _, alias := range cmd.aliases {
+			if !strings.Contains(got, alias) {
+				t.Errorf("Help does not list alias %v of %v", alias, cmd.name)
+			}
+		}
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	tests := []struct {
+		typed string
+		want  string
+	}{
+		{"lnn", "ln"},
+		{"LNN", "ln"},
+		{"lfs", "lf"},
+		{"hlep", "help"},
+		{"folio", "folios"},
+		{"dlete", "delete"},
+		{"udno", "undo"},
+		{"nxt", "next"},
+		{"apend", "append"},
+		{"x", "h"},
+		{"xyz", ""},
+		{"groceries", ""},
+		{"zzzz", ""},
+	}
+
+	for _, test := range tests {
+		if got := suggest(test.typed); got != test.want {
+			t.Errorf("suggest(%q) = %q, want %q", test.typed, got, test.want)
+		}
+	}
+}
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"ln", "ln", 0},
+		{"", "undo", 4},
+		{"lnn", "ln", 1},
+		{"hlep", "help", 2},
+		{"kitten", "sitting", 3},
+		{"✅", "✔", 1},
+	}
+
+	for _, test := range tests {
+		if got := distance(test.a, test.b); got != test.want {
+			t.Errorf("distance(%q, %q) = %v, want %v", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestFolioOmittedCachesFolios(t *testing.T) {
+	// Count how often the folios are listed on the way to the API
+	target, err := url.Parse(testAPI(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	listed := 0
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && r.URL.Path == "/folios" {
+			listed++
+		}
+		proxy.ServeHTTP(w, r)
+	}))
+	t.Cleanup(api.Close)
+
+	user := senders(testConfig(api.URL))[testNumber]
+	conv := conversation{}
+	texts := []struct {
+		text   string
+		want   string
+		listed int // Times the folios have been listed after the text
+	}{
+		{"cf groceries", "Created folio with name groceries", 0},
+		{"use groceries", "Using folio groceries", 1},
+		{"a milk", "Appended to groceries", 1},
+		{"a oat milk", "Appended to groceries", 1},
+		{"a groceries eggs", "Appended to groceries", 1},
+		{"cf chores", "Created folio with name chores", 1},
+		{"a chores sweep", "Appended to chores", 2},
+		{"a chores mop", "Appended to chores", 2},
+		{"a bread", "Appended to groceries", 2},
+	}
+
+	for _, text := range texts {
+		got, err := messageResponse(text.text, user, &conv, defaultPageSize)
+		if err != nil {
+			got = err.Error()
+		}
+		if got != text.want {
+			t.Errorf("Texting %q got %q, want %q", text.text, got, text.want)
+		}
+		if listed != text.listed {
+			t.Errorf("After texting %q folios were listed %v times, want %v", text.text, listed, text.listed)
+		}
+	}
+}
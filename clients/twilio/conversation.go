@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// confirmFor is how long a command waits to be confirmed before it is forgotten
+const confirmFor = 5 * time.Minute
+
+// conversation is what the client remembers about a sender between texts
+type conversation struct {
+	Folio   string   `json:"folio,omitempty"`   // Used by commands the folio is left out of, set by use or by listing a folio
+	Listed  []int    `json:"listed,omitempty"`  // Note numbers last listed from Folio
+	Pending *pending `json:"pending,omitempty"` // Command waiting to be confirmed with yes
+	Pages   []string `json:"pages,omitempty"`   // Pages of the last reply not yet sent, texted one at a time with more
+}
+
+// pending is a command that needs confirming before it runs, like deleting a folio
+type pending struct {
+	Command string    `json:"command"`
+	Args    []string  `json:"args"`
+	Expires time.Time `json:"expires"`
+}
+
+// conversations are every sender's conversation, saved to a file so they survive restarts
+type conversations struct {
+	mu       *sync.Mutex
+	path     string
+	byNumber map[string]*conversation
+}
+
+// loadConversations reads conversations from path, starting afresh if it does not exist
+func loadConversations(path string) (*conversations, error) {
+	c := &conversations{mu: &sync.Mutex{}, path: path, byNumber: map[string]*conversation{}}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(data, &c.byNumber); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// get returns a copy of the conversation with number, to be changed and passed to set
+func (c *conversations) get(number string) conversation {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if conv, ok := c.byNumber[number]; ok {
+		return *conv
+	}
+	return conversation{}
+}
+
+// set replaces the conversation with number and saves every conversation
+func (c *conversations) set(number string, conv conversation) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.byNumber[number] = &conv
+	data, err := json.MarshalIndent(c.byNumber, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so a crash can't leave a half written file
+	tmp := c.path + ".tmp"
+	if err = os.MkdirAll(filepath.Dir(c.path), 0700); err != nil {
+		return err
+	}
+	if err = os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
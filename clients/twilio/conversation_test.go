@@ -0,0 +1,65 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestConversationsRoundTrip(t *testing.T) {
+	// The directory is created on the first save
+	path := filepath.Join(t.TempDir(), "state", "conversations.json")
+	saved := map[string]conversation{
+		"+15550001111": {Folio: "groceries", Listed: []int{2, 5}, Pages: []string{"(2/2)\n3. bread"}},
+		"+15550002222": {
+			Folio:   "chores",
+			Pending: &pending{Command: "df", Args: []string{"chores"}, Expires: time.Date(2024, 5, 1, 12, 5, 0, 0, time.UTC)},
+		},
+	}
+
+	convs, err := loadConversations(path)
+	if err != nil {
+		t.Fatalf("Loading from a missing file: %v", err)
+	}
+	if conv := convs.get("+15550001111"); !reflect.DeepEqual(conv, conversation{}) {
+		t.Errorf("A new store has conversation %+v", conv)
+	}
+	for number, conv := range saved {
+		if err := convs.set(number, conv); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Changing a conversation got from the store does not change what is stored until it is set
+	conv := convs.get("+15550001111")
+	conv.Folio = "work"
+	if got := convs.get("+15550001111").Folio; got != "groceries" {
+		t.Errorf("Changing a copy changed the stored folio to %q", got)
+	}
+
+	reloaded, err := loadConversations(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for number, want := range saved {
+		if got := reloaded.get(number); !reflect.DeepEqual(got, want) {
+			t.Errorf("Reloaded conversation with %v is %+v, want %+v", number, got, want)
+		}
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("Temporary file was left behind: %v", err)
+	}
+}
+
+func TestLoadCorruptConversations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "conversations.json")
+	if err := os.WriteFile(path, []byte(`{"+15550001111": {"folio": "groc`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := loadConversations(path); err == nil {
+		t.Error("Loading a corrupt file succeeded")
+	}
+}
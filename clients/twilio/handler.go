@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/appened/HTTPLogger"
+	"github.com/twilio/twilio-go"
+)
+
+// smsHandler answers text messages sent to the Twilio number
+func smsHandler(config Config, logger *HTTPLogger.Logger, users map[string]*sender, conversations *conversations, twilioClient *twilio.RestClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			logger.Warn("Could not parse webhook request: " + err.Error())
+			writeTwiML(w, http.StatusBadRequest, "Sorry, your message could not be read")
+			return
+		}
+
+		// Get the income message and phone number of user
+		incomingMsg, phoneNumber := r.PostForm.Get("Body"), r.PostForm.Get("From")
+		if phoneNumber == "" {
+			logger.Warn("Webhook request is missing From")
+			writeTwiML(w, http.StatusBadRequest)
+			return
+		}
+		if _, ok := r.PostForm["Body"]; !ok {
+			logger.Warn("Webhook request from " + phoneNumber + " is missing Body")
+			writeTwiML(w, http.StatusBadRequest, "Sorry, your message could not be read")
+			return
+		}
+
+		// Ensure it is a whitelisted number
+		user, ok := users[phoneNumber]
+		if !ok {
+			logger.Info("Incoming text from invalid number " + phoneNumber)
+			writeTwiML(w, http.StatusForbidden)
+			return
+		}
+
+		// Create response to message
+		msg, inputErr := converse(incomingMsg, phoneNumber, user, conversations, config, logger)
+		if inputErr != nil {
+			logger.Info("Error in message: " + inputErr.Error())
+			msg = inputErr.Error()
+		}
+
+		// Reply in the webhook's response, or with a separate request to Twilio's API
+		if config.InlineReplies {
+			if err := writeTwiML(w, http.StatusOK, msg); err != nil {
+				logger.Error(err)
+			} else {
+				logger.Info("Replied to SMS")
+			}
+			return
+		}
+
+		if err := sendSMS(msg, phoneNumber, config, twilioClient); err != nil {
+			logger.Error(err)
+		} else {
+			logger.Info("Replied to SMS")
+		}
+		writeTwiML(w, http.StatusOK)
+	}
+}
+
+// converse answers a text from user and saves what should be remembered for their next one. Their texts are
+// answered one at a time so their conversation is updated in order, and the lock is released even if answering panics.
+func converse(msg string, phoneNumber string, user *sender, conversations *conversations, config Config, logger *HTTPLogger.Logger) (string, error) {
+	user.mu.Lock()
+	defer user.mu.Unlock()
+
+	conv := conversations.get(phoneNumber)
+	reply, err := messageResponse(msg, user, &conv, config.PageSize)
+	if err := conversations.set(phoneNumber, conv); err != nil {
+		logger.Error(err)
+	}
+	return reply, err
+}
+
+// recoverPanics stops a panic while handling one message from taking down the client, replying with an error instead
+func recoverPanics(logger *HTTPLogger.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if p := recover(); p != nil {
+				if p == http.ErrAbortHandler {
+					panic(p)
+				}
+				logger.Error(fmt.Errorf("Panic handling webhook request: %v\n%s", p, debug.Stack()))
+				writeTwiML(w, http.StatusInternalServerError, "Sorry, something went wrong")
+			}
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
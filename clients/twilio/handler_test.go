@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/appened/HTTPLogger"
+	"github.com/appened/note"
+	"github.com/appened/server"
+)
+
+// testNumber is the only number allowed to text the client in tests
+const testNumber = "+15550001111"
+
+// testLogger discards everything logged
+func testLogger() *HTTPLogger.Logger {
+	return HTTPLogger.New(io.Discard, HTTPLogger.LOG_ALL)
+}
+
+// testAPI serves the 'Appened API from an empty store for the length of a test, returning its URL
+func testAPI(t *testing.T) string {
+	t.Helper()
+
+	s, err := server.New(server.Config{
+		Auth:   server.TokenAuth(server.Token{Name: "twilio", Secret: "token"}),
+		Store:  note.NewStore(t.TempDir()),
+		Logger: testLogger(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := httptest.NewServer(s)
+	t.Cleanup(func() {
+		api.Close()
+		s.Shutdown(context.Background())
+	})
+
+	return api.URL
+}
+
+// testConfig is a config for testNumber acting on the API at url, replying inline
+func testConfig(url string) Config {
+	return Config{
+		Users:         []User{{Number: testNumber}},
+		AppenedToken:  "token",
+		AppenedURL:    url,
+		InlineReplies: true,
+		PageSize:      defaultPageSize,
+	}
+}
+
+// testHandler answers texts as the client does, without checking signatures
+func testHandler(t *testing.T, config Config) http.Handler {
+	t.Helper()
+
+	conversations, err := loadConversations(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := testLogger()
+	return recoverPanics(logger, smsHandler(config, logger, senders(config), conversations, nil))
+}
+
+// text posts a webhook request with body to handler, returning the status and the messages replied with
+func text(t *testing.T, handler http.Handler, body string) (int, []string) {
+	t.Helper()
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	if contentType := w.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/xml") {
+		t.Fatalf("Got Content-Type %q, want TwiML", contentType)
+	}
+	reply := twimlResponse{}
+	if err := xml.Unmarshal(w.Body.Bytes(), &reply); err != nil {
+		t.Fatalf("Reply is not TwiML: %v\n%s", err, w.Body.String())
+	}
+	return w.Code, reply.Messages
+}
+
+func TestSMSHandler(t *testing.T) {
+	// A command that panics, to check a bug answering one text doesn't take down the client
+	panics := &command{name: "panic", help: "panic", run: func(c *chat, args []string) (string, error) {
+		panic("Answering text failed")
+	}}
+	commands = append(commands, panics)
+	t.Cleanup(func() { commands = commands[:len(commands)-1] })
+
+	tests := []struct {
+		name     string
+		body     string
+		status   int
+		messages []string
+	}{
+		{"text", "From=%2B15550001111&Body=lf", http.StatusOK, []string{"No folios yet!"}},
+		{"unparsable form", "From=%2B15550001111&Body=%zz", http.StatusBadRequest, []string{"Sorry, your message could not be read"}},
+		{"missing From", "Body=lf", http.StatusBadRequest, nil},
+		{"missing Body", "From=%2B15550001111", http.StatusBadRequest, []string{"Sorry, your message could not be read"}},
+		{"empty Body", "From=%2B15550001111&Body=", http.StatusOK, []string{"Empty text message received"}},
+		{"unknown sender", "From=%2B15550009999&Body=lf", http.StatusForbidden, nil},
+		{"command panics", "From=%2B15550001111&Body=panic", http.StatusInternalServerError, []string{"Sorry, something went wrong"}},
+		{"text after a panic", "From=%2B15550001111&Body=lf", http.StatusOK, []string{"No folios yet!"}},
+	}
+
+	handler := testHandler(t, testConfig(testAPI(t)))
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			status, messages := text(t, handler, test.body)
+			if status != test.status {
+				t.Errorf("Got status %v, want %v", status, test.status)
+			}
+			if !reflect.DeepEqual(messages, test.messages) {
+				t.Errorf("Got messages %q, want %q", messages, test.messages)
+			}
+		})
+	}
+}
@@ -2,31 +2,38 @@ package main
 
 import (
 	"encoding/json"
-	"errors"
+	"fmt"
 	"io/ioutil"
-	"log"
 	"net/http"
-	"net/url"
 	"os"
-	"strconv"
-	"strings"
 
 	"github.com/appened/HTTPLogger"
-	appendedGo "github.com/appened/clients/go-sdk"
 	"github.com/gorilla/mux"
 	"github.com/twilio/twilio-go"
 	openapi "github.com/twilio/twilio-go/rest/api/v2010"
 )
 
-// TODO: Check that X-Twilio-Signature header to ensure request is authentically from Twilio
-
 type Config struct {
 	AccountSid   string `json:"accountSid"`
 	AuthToken    string `json:"authToken"`
-	ClientNumber string `json:"clientNumber"`
 	TwilioNumber string `json:"twilioNumber"`
+	Users        []User `json:"users"`        // Phone numbers that may text the client
+	ClientNumber string `json:"clientNumber"` // Deprecated: a single number that may text the client, use Users
 	AppenedToken string `json:"appenedToken"`
 	AppenedURL   string `json:"appenedURL"`
+	LogLevel     string `json:"logLevel"` // debug, info, warn, error, or none, defaults to debug
+
+	// PublicURL is the webhook's URL as configured in Twilio, e.g. https://sms.example.com, which requests are signed
+	// for. Without it the URL is rebuilt from the request, using X-Forwarded-Proto and -Host if TrustProxyHeaders is set.
+	PublicURL         string `json:"publicURL"`
+	TrustProxyHeaders bool   `json:"trustProxyHeaders"`
+
+	// InlineReplies answers texts with TwiML in the webhook's response, rather than a separate request to Twilio's API
+	InlineReplies bool `json:"inlineReplies"`
+
+	PageSize  int                    `json:"pageSize"`  // Longest reply in characters before it is split into pages, defaults to 320
+	StatePath string                 `json:"statePath"` // Where each sender's current folio and pending confirmations are kept, defaults to state.json
+	LogFiles  HTTPLogger.FileOptions `json:"logFiles"`  // Files to log to instead of stdout, and how to rotate them
 }
 
 func main() {
@@ -46,6 +53,18 @@ func main() {
 	}
 	config := Config{}
 	json.Unmarshal(configBytes, &config)
+	sinks, err := config.LogFiles.Open(os.Stdout)
+	if err != nil {
+		logger.Error(err)
+	} else {
+		defer sinks.Close()
+		logger = HTTPLogger.NewWithSinks(sinks, HTTPLogger.LOG_ALL, HTTPLogger.FormatText)
+	}
+	if config.LogLevel != "" {
+		if err := logger.SetLevel(config.LogLevel); err != nil {
+			logger.Error(err)
+		}
+	}
 	logger.Info("Loaded config successfully")
 
 	// Init twilio client
@@ -54,56 +73,44 @@ func main() {
 		Password: config.AuthToken,
 	})
 
-	// Init appended client
-	appendedClient := appendedGo.New(config.AppenedToken, config.AppenedURL)
-
-	r := mux.NewRouter()
-	r.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		body, err := ioutil.ReadAll(r.Body)
-		if err != nil {
-			log.Fatalf("Error decoding request body:\n%v", err)
-		}
-
-		bodyMap, err := url.ParseQuery(string(body))
-		if err != nil {
-			log.Fatalf("Error converting body to map:\n%v", err)
-		}
-
-		// Get the income message and phone number of user
-		incomingMsg := bodyMap["Body"][0]
-		phoneNumber := bodyMap["From"][0]
+	// Init an appended client for each number allowed to text
+	users := senders(config)
+	logger.Info(fmt.Sprintf("Accepting texts from %v numbers", len(users)))
 
-		// Ensure it is the one whitelisted number
-		if phoneNumber != config.ClientNumber {
-			logger.Info("Incoming text from invalid number" + phoneNumber)
-			return
-		}
+	if config.PageSize <= 0 {
+		config.PageSize = defaultPageSize
+	}
 
-		// Create response to message
-		msg, inputErr := messageResponse(incomingMsg, appendedClient)
-		if inputErr != nil {
-			if smsErr := sendSMS(inputErr.Error(), config, twilioClient); smsErr != nil {
-				logger.Error(smsErr)
-			}
-			logger.Info("Error in message: " + inputErr.Error())
-			return
-		}
+	// Load what was remembered about each sender
+	if config.StatePath == "" {
+		config.StatePath = "state.json"
+	}
+	conversations, err := loadConversations(config.StatePath)
+	if err != nil {
+		logger.Error(err)
+		os.Exit(1)
+	}
 
-		if err = sendSMS(msg, config, twilioClient); err != nil {
-			logger.Error(err)
-		} else {
-			logger.Info("Replied to SMS")
-		}
+	r := mux.NewRouter()
+	r.Use(func(next http.Handler) http.Handler {
+		return recoverPanics(logger, next)
+	})
+	r.Use(func(next http.Handler) http.Handler {
+		return requireSignature(config, logger, next)
 	})
+	r.HandleFunc("/", smsHandler(config, logger, users, conversations, twilioClient)).Methods("POST")
 	logger.Info("Listening on port 8080")
 	if err = http.ListenAndServe(":8080", r); err != nil {
-		log.Fatalf("Error starting on server on ':8080':\n%v\n", err)
+		logger.Error(fmt.Errorf("Error starting on server on ':8080': %w", err))
+		os.Exit(1)
 	}
 }
 
-func sendSMS(msg string, config Config, twilioClient *twilio.RestClient) error {
+// sendSMS texts msg to a phone number from the Twilio number through Twilio's API. Replies to texts are sent
+// inline instead when InlineReplies is set, so this is left for messages that aren't replies, like reminders.
+func sendSMS(msg string, to string, config Config, twilioClient *twilio.RestClient) error {
 	params := &openapi.CreateMessageParams{}
-	params.SetTo(config.ClientNumber)
+	params.SetTo(to)
 	params.SetFrom(config.TwilioNumber)
 	params.SetBody(msg)
 
@@ -113,121 +120,3 @@ func sendSMS(msg string, config Config, twilioClient *twilio.RestClient) error {
 	}
 	return nil
 }
-
-func messageResponse(msg string, client *appendedGo.Client) (string, error) {
-	words := strings.Split(strings.TrimSpace(msg), " ")
-	if len(words) == 0 {
-		return "", errors.New("Empty text message received")
-	}
-
-	cmd := strings.ToLower(words[0])
-
-	// TODO: Find a tidier way to do this
-	if len(words) == 1 {
-		if cmd == "h" {
-			msg := "h: this message"
-			msg += "\nlf: list folios"
-			msg += "\ncf <folioName>: create folio"
-			msg += "\ndf <folioName>: delete folio"
-			msg += "\nln <folioName>: list notes in folio"
-			msg += "\nlna <folioName>: list all notes in folio, including done"
-			msg += "\nlnd <folioName>: list all done notes in folio"
-			msg += "\ndn <folioName> <number>: Toggle done on note at number"
-			msg += "\na <folioName> <msg>: append note to folio"
-
-			return msg, nil
-
-		} else if cmd == "lf" {
-			folioNames, err := client.GetFolios()
-			if err != nil {
-				return "", err
-			}
-			if len(folioNames) == 0 {
-				return "No folios yet!", nil
-			}
-			return strings.Join(folioNames, "\n"), nil
-		}
-	} else if len(words) == 2 {
-		folioName := words[1]
-		if cmd == "cf" {
-			// create folio
-			if err := client.CreateFolio(folioName); err != nil {
-				return "", err
-			} else {
-				return "Created folio with name " + folioName, nil
-			}
-		} else if cmd == "ln" {
-			notes, err := client.GetNotes(folioName)
-			if err != nil {
-				return "", err
-			}
-
-			filteredNotes := make([]string, 0)
-			for _, note := range notes {
-				if !strings.Contains(note, "✅") {
-					filteredNotes = append(filteredNotes, note)
-				}
-
-			}
-			if len(filteredNotes) == 0 {
-				return "No unfinished notes!", nil
-			}
-
-			return strings.Join(filteredNotes, "\n"), nil
-		} else if cmd == "lnd" {
-			notes, err := client.GetNotes(folioName)
-			if err != nil {
-				return "", err
-			}
-
-			filteredNotes := make([]string, 0)
-			for _, note := range notes {
-				if strings.Contains(note, "✅") {
-					filteredNotes = append(filteredNotes, note)
-				}
-			}
-
-			if len(filteredNotes) == 0 {
-				return "Nothing finished yet!", nil
-			}
-
-			return strings.Join(filteredNotes, "\n"), nil
-		} else if cmd == "lna" {
-			notes, err := client.GetNotes(folioName)
-			if err != nil {
-				return "", err
-			}
-
-			if len(notes) == 0 {
-				return "No notes yet!", nil
-			}
-			return strings.Join(notes, "\n"), nil
-		} else if cmd == "df" {
-			if err := client.DeleteFolio(folioName); err != nil {
-				return "", err
-			}
-			return "Deleted folio", nil
-		}
-	} else {
-		folioName := words[1]
-		note := strings.Join(words[2:], " ")
-		if cmd == "a" {
-			if err := client.AddNote(folioName, note); err != nil {
-				return "", err
-			}
-			return "Appended", nil
-		}
-		if cmd == "dn" && len(words) == 3 {
-			index, err := strconv.Atoi(words[2])
-			if err != nil {
-				return "", err
-			}
-			if err := client.ToggleDone(folioName, index-1); err != nil {
-				return "", err
-			}
-			return "Toggled done", nil
-		}
-	}
-
-	return "", errors.New("Invalid command")
-}
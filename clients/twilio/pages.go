@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// defaultPageSize keeps a page of notes to a few SMS segments
+const defaultPageSize = 320
+
+// moreHint ends every page but the last
+const moreHint = "\nText more for the next page"
+
+// paginate splits a reply into pages of at most size characters, breaking between lines so notes stay
+// whole where they fit. Each page is numbered like (1/4), and every page but the last ends with moreHint.
+func paginate(reply string, size int) []string {
+	if utf8.RuneCountInString(reply) <= size {
+		return []string{reply}
+	}
+
+	// Leave room for the header and hint on every page. Numbering more pages takes more room, so
+	// split again with a longer header until it fits the number of pages.
+	pages, digits := []string{}, 1
+	for {
+		header := utf8.RuneCountInString(fmt.Sprintf("(%v/%v)\n", strings.Repeat("9", digits), strings.Repeat("9", digits)))
+		pages = splitPages(reply, size-header-utf8.RuneCountInString(moreHint))
+		if len(strconv.Itoa(len(pages))) <= digits {
+			break
+		}
+		digits = len(strconv.Itoa(len(pages)))
+	}
+
+	for i := range pages {
+		pages[i] = fmt.Sprintf("(%v/%v)\n%v", i+1, len(pages), pages[i])
+		if i < len(pages)-1 {
+			pages[i] += moreHint
+		}
+	}
+	return pages
+}
+
+// splitPages splits a reply into pages of at most room characters, breaking between lines where it can
+func splitPages(reply string, room int) []string {
+	if room < 1 {
+		room = 1
+	}
+
+	pages := []string{}
+	page, started := "", false
+	for _, line := range strings.Split(reply, "\n") {
+		for _, part := range splitRunes(line, room) {
+			switch {
+			case !started:
+				page, started = part, true
+			case utf8.RuneCountInString(page)+1+utf8.RuneCountInString(part) <= room:
+				page += "\n" + part
+			default:
+				pages = append(pages, page)
+				page = part
+			}
+		}
+	}
+	return append(pages, page)
+}
+
+// splitRunes splits a line too long for any page into pieces of at most size characters
+func splitRunes(line string, size int) []string {
+	runes := []rune(line)
+	if len(runes) <= size {
+		return []string{line}
+	}
+
+	parts := []string{}
+	for len(runes) > size {
+		parts = append(parts, string(runes[:size]))
+		runes = runes[size:]
+	}
+	return append(parts, string(runes))
+}
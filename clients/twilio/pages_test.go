@@ -0,0 +1,127 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestPaginate(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply string
+		size  int
+		want  []string
+	}{
+		{"fits", "1. milk\n2. eggs", 320, []string{"1. milk\n2. eggs"}},
+		{"fits exactly", "12345", 5, []string{"12345"}},
+		{"empty", "", 320, []string{""}},
+		{
+			name:  "breaks between lines",
+			reply: "1. milk\n2. eggs\n3. bread\n4. oat milk\n5. coffee\n6. tea\n7. honey",
+			size:  60,
+			want: []string{
+				"(1/3)\n1. milk\n2. eggs\n3. bread" + moreHint,
+				"(2/3)\n4. oat milk\n5. coffee" + moreHint,
+				"(3/3)\n6. tea\n7. honey",
+			},
+		},
+		{
+			name:  "keeps blank lines",
+			reply: "Groceries\n\n1. milk and eggs\n\n\n2. bread\n3. oat milk\n\n4. coffee",
+			size:  60,
+			want: []string{
+				"(1/4)\nGroceries\n" + moreHint,
+				"(2/4)\n1. milk and eggs\n\n" + moreHint,
+				"(3/4)\n2. bread\n3. oat milk\n" + moreHint,
+				"(4/4)\n4. coffee",
+			},
+		},
+		{
+			name:  "keeps a blank line starting a page",
+			reply: strings.Repeat("a", 26) + "\n\n" + strings.Repeat("b", 10) + "\n" + strings.Repeat("c", 20) + "\n" + strings.Repeat("d", 5),
+			size:  60,
+			want: []string{
+				"(1/3)\n" + strings.Repeat("a", 26) + moreHint,
+				"(2/3)\n\n" + strings.Repeat("b", 10) + moreHint,
+				"(3/3)\n" + strings.Repeat("c", 20) + "\n" + strings.Repeat("d", 5),
+			},
+		},
+		{
+			name:  "splits long lines",
+			reply: strings.Repeat("a", 70),
+			size:  60,
+			want: []string{
+				"(1/3)\n" + strings.Repeat("a", 26) + moreHint,
+				"(2/3)\n" + strings.Repeat("a", 26) + moreHint,
+				"(3/3)\n" + strings.Repeat("a", 18),
+			},
+		},
+		{
+			name:  "numbers ten pages or more",
+			reply: strings.Repeat("a", 250),
+			size:  60,
+			want: []string{
+				"(1/11)\n" + strings.Repeat("a", 24) + moreHint,
+				"(2/11)\n" + strings.Repeat("a", 24) + moreHint,
+				"(3/11)\n" + strings.Repeat("a", 24) + moreHint,
+				"(4/11)\n" + strings.Repeat("a", 24) + moreHint,
+				"(5/11)\n" + strings.Repeat("a", 24) + moreHint,
+				"(6/11)\n" + strings.Repeat("a", 24) + moreHint,
+				"(7/11)\n" + strings.Repeat("a", 24) + moreHint,
+				"(8/11)\n" + strings.Repeat("a", 24) + moreHint,
+				"(9/11)\n" + strings.Repeat("a", 24) + moreHint,
+				"(10/11)\n" + strings.Repeat("a", 24) + moreHint,
+				"(11/11)\n" + strings.Repeat("a", 10),
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := paginate(test.reply, test.size)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Got pages %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestPaginateSize(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines int
+		width int // Lines are up to this long, short enough that none is split
+		size  int
+	}{
+		{"a few pages", 100, 40, defaultPageSize},
+		{"over 99 pages", 500, 10, 50},
+		{"over 999 pages", 5000, 10, 50},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			lines := []string{}
+			for i := 0; i < test.lines; i++ {
+				lines = append(lines, strings.Repeat("ü", i%test.width))
+			}
+			reply := strings.Join(lines, "\n")
+
+			pages := paginate(reply, test.size)
+			kept := []string{}
+			for i, page := range pages {
+				if n := utf8.RuneCountInString(page); n > test.size {
+					t.Errorf("Page %v of %v is %v characters, longer than %v", i+1, len(pages), n, test.size)
+				}
+				page = page[strings.Index(page, "\n")+1:]
+				kept = append(kept, strings.TrimSuffix(page, moreHint))
+			}
+
+			// Pages break between lines, so joining them gives back every line, blank ones included
+			if got := strings.Join(kept, "\n"); got != reply {
+				t.Errorf("Pages joined are not the reply:\n%q\nwant:\n%q", got, reply)
+			}
+		})
+	}
+}
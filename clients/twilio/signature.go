@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/appened/HTTPLogger"
+	"github.com/twilio/twilio-go/client"
+)
+
+// signatureHeader carries Twilio's HMAC-SHA1 signature of the webhook's URL and parameters
+const signatureHeader = "X-Twilio-Signature"
+
+// requireSignature rejects requests that are not signed by Twilio with the account's auth token, so that
+// anyone who can reach the webhook cannot act as a whitelisted number by spoofing From.
+func requireSignature(config Config, logger *HTTPLogger.Logger, next http.Handler) http.Handler {
+	validator := client.NewRequestValidator(config.AuthToken)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signature := r.Header.Get(signatureHeader)
+		if signature == "" {
+			logger.Warn("Rejected request without " + signatureHeader + " from " + r.RemoteAddr)
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		// Form posts are signed over their parameters, JSON bodies over a hash of the body given in the URL
+		signedURL := webhookURL(r, config)
+		valid := false
+		if strings.Contains(r.URL.RawQuery, "bodySHA256=") {
+			valid = validator.ValidateBody(signedURL, body, signature)
+		} else if params, err := url.ParseQuery(string(body)); err == nil {
+			valid = validator.Validate(signedURL, firstValues(params), signature)
+		}
+
+		if !valid {
+			logger.Warn("Rejected request with invalid " + signatureHeader + " from " + r.RemoteAddr + " for " + signedURL)
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// webhookURL returns the URL Twilio requested, which the signature covers. Behind a proxy the URL that
+// reaches the client differs, so it is taken from publicURL, or the proxy's X-Forwarded headers if trusted.
+func webhookURL(r *http.Request, config Config) string {
+	if config.PublicURL != "" {
+		signed := strings.TrimSuffix(config.PublicURL, "/") + r.URL.Path
+		if r.URL.RawQuery != "" {
+			signed += "?" + r.URL.RawQuery
+		}
+		return signed
+	}
+
+	scheme, host := "http", r.Host
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if config.TrustProxyHeaders {
+		if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+			scheme = proto
+		}
+		if forwarded := r.Header.Get("X-Forwarded-Host"); forwarded != "" {
+			host = forwarded
+		}
+	}
+	return scheme + "://" + host + r.URL.RequestURI()
+}
+
+// firstValues flattens form parameters, which Twilio never repeats
+func firstValues(params url.Values) map[string]string {
+	values := map[string]string{}
+	for key := range params {
+		values[key] = params.Get(key)
+	}
+	return values
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/appened/HTTPLogger"
+)
+
+// Requests as Twilio sends them, signed with testAuthToken: the base64 HMAC-SHA1 of the URL followed by
+// each parameter's name and value in sorted order, or of the URL alone for a JSON body
+const (
+	testAuthToken = "12345"
+
+	// An incoming text, signed for https://sms.example.com/
+	smsBody      = "ToCountry=US&ToState=CA&SmsMessageSid=SM5f0b3b2e4f9c4a3d8e6b1a2c3d4e5f60&NumMedia=0&ToCity=&FromZip=&SmsSid=SM5f0b3b2e4f9c4a3d8e6b1a2c3d4e5f60&FromState=NY&SmsStatus=received&FromCity=&Body=ln+groceries&FromCountry=US&To=%2B15559998888&ToZip=&NumSegments=1&MessageSid=SM5f0b3b2e4f9c4a3d8e6b1a2c3d4e5f60&AccountSid=AC0123456789abcdef0123456789abcdef&From=%2B15550001111&ApiVersion=2010-04-01"
+	smsSignature = "v5EoJ1T4LXsQl8q4r2+r8yWfYzY="
+
+	// The example from Twilio's documentation, signed for https://mycompany.com/myapp.php?foo=1&bar=2
+	docsBody      = "CallSid=CA1234567890ABCDE&Caller=%2B12349013030&Digits=1234&From=%2B12349013030&To=%2B18005551212"
+	docsSignature = "0/KCTR6DLpKmkAf8muzZqo1nDgQ="
+
+	// A JSON body, signed for https://sms.example.com/ with its SHA-256 in the bodySHA256 parameter
+	jsonBody      = `{"Body":"ln groceries","From":"+15550001111"}`
+	jsonQuery     = "bodySHA256=d6ed9132074b0ec7250812552de125e5fb305f206d3acccf80ce3f5566d2fb64"
+	jsonSignature = "DW178jIdsSnY/Gt18JXRGldMrLg="
+)
+
+func TestRequireSignature(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    Config
+		target    string // URL the request reaches the client on
+		headers   map[string]string
+		body      string
+		signature string
+		status    int
+	}{
+		{
+			name:      "valid signature",
+			target:    "https://sms.example.com/",
+			body:      smsBody,
+			signature: smsSignature,
+			status:    http.StatusOK,
+		},
+		{
+			name:      "documented example",
+			target:    "https://mycompany.com/myapp.php?foo=1&bar=2",
+			body:      docsBody,
+			signature: docsSignature,
+			status:    http.StatusOK,
+		},
+		{
+			name:      "JSON body",
+			target:    "https://sms.example.com/?" + jsonQuery,
+			body:      jsonBody,
+			signature: jsonSignature,
+			status:    http.StatusOK,
+		},
+		{
+			name:      "tampered signature",
+			target:    "https://sms.example.com/",
+			body:      smsBody,
+			signature: "w5EoJ1T4LXsQl8q4r2+r8yWfYzY=",
+			status:    http.StatusForbidden,
+		},
+		{
+			name:      "tampered parameters",
+			target:    "https://sms.example.com/",
+			body:      strings.Replace(smsBody, "From=%2B15550001111", "From=%2B15550002222", 1),
+			signature: smsSignature,
+			status:    http.StatusForbidden,
+		},
+		{
+			name:      "tampered JSON body",
+			target:    "https://sms.example.com/?" + jsonQuery,
+			body:      strings.Replace(jsonBody, "groceries", "chores", 1),
+			signature: jsonSignature,
+			status:    http.StatusForbidden,
+		},
+		{
+			name:      "signed with another token",
+			config:    Config{AuthToken: "54321"},
+			target:    "https://sms.example.com/",
+			body:      smsBody,
+			signature: smsSignature,
+			status:    http.StatusForbidden,
+		},
+		{
+			name:   "missing signature",
+			target: "https://sms.example.com/",
+			body:   smsBody,
+			status: http.StatusForbidden,
+		},
+		{
+			name:      "public URL",
+			config:    Config{PublicURL: "https://sms.example.com"},
+			target:    "http://localhost:8080/",
+			body:      smsBody,
+			signature: smsSignature,
+			status:    http.StatusOK,
+		},
+		{
+			name:      "public URL with trailing slash and query",
+			config:    Config{PublicURL: "https://mycompany.com/"},
+			target:    "http://localhost:8080/myapp.php?foo=1&bar=2",
+			body:      docsBody,
+			signature: docsSignature,
+			status:    http.StatusOK,
+		},
+		{
+			name:      "behind a proxy without public URL",
+			target:    "http://localhost:8080/",
+			body:      smsBody,
+			signature: smsSignature,
+			status:    http.StatusForbidden,
+		},
+		{
+			name:      "trusted proxy headers",
+			config:    Config{TrustProxyHeaders: true},
+			target:    "http://localhost:8080/",
+			headers:   map[string]string{"X-Forwarded-Proto": "https", "X-Forwarded-Host": "sms.example.com"},
+			body:      smsBody,
+			signature: smsSignature,
+			status:    http.StatusOK,
+		},
+		{
+			name:      "untrusted proxy headers",
+			target:    "http://localhost:8080/",
+			headers:   map[string]string{"X-Forwarded-Proto": "https", "X-Forwarded-Host": "sms.example.com"},
+			body:      smsBody,
+			signature: smsSignature,
+			status:    http.StatusForbidden,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.config.AuthToken == "" {
+				test.config.AuthToken = testAuthToken
+			}
+
+			// The handler after the check must still be able to read the body
+			var received string
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				received = string(body)
+			})
+			handler := requireSignature(test.config, HTTPLogger.New(io.Discard, HTTPLogger.LOG_ALL), next)
+
+			r := httptest.NewRequest(http.MethodPost, test.target, strings.NewReader(test.body))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if test.signature != "" {
+				r.Header.Set(signatureHeader, test.signature)
+			}
+			for name, value := range test.headers {
+				r.Header.Set(name, value)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Code != test.status {
+				t.Fatalf("Got status %v, want %v", w.Code, test.status)
+			}
+			if test.status == http.StatusOK && received != test.body {
+				t.Errorf("Next handler read body %q, want %q", received, test.body)
+			}
+			if test.status != http.StatusOK && received != "" {
+				t.Errorf("Next handler was called for a rejected request")
+			}
+		})
+	}
+}
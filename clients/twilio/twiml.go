@@ -0,0 +1,28 @@
+package main
+
+import (
+	"encoding/xml"
+	"net/http"
+)
+
+// twimlResponse is a TwiML document replying with zero or more messages
+type twimlResponse struct {
+	XMLName  xml.Name `xml:"Response"`
+	Messages []string `xml:"Message"`
+}
+
+// writeTwiML responds to a webhook with TwiML, replying to the sender with messages
+func writeTwiML(w http.ResponseWriter, status int, messages ...string) error {
+	body, err := xml.Marshal(twimlResponse{Messages: messages})
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+	w.WriteHeader(status)
+	if _, err = w.Write([]byte(xml.Header)); err != nil {
+		return err
+	}
+	_, err = w.Write(body)
+	return err
+}
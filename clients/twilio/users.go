@@ -0,0 +1,66 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	appendedGo "github.com/appened/clients/go-sdk"
+)
+
+// folioCacheFor is how long a sender's folio names are trusted before they are fetched again
+const folioCacheFor = time.Minute
+
+// User is a phone number allowed to text the client, and how it may use 'Appened
+type User struct {
+	Number       string `json:"number"`       // In E.164 format, as Twilio sends it, e.g. +15551234567
+	AppenedToken string `json:"appenedToken"` // Token to act with, so the server knows who made each change. Defaults to the top-level appenedToken
+	ReadOnly     bool   `json:"readOnly"`     // Only allow commands that don't change anything
+}
+
+// sender is a user who has texted, with an 'Appened client that acts for them
+type sender struct {
+	User
+	client *appendedGo.Client
+	mu     *sync.Mutex // Held while answering a text, so their conversation is updated in order
+
+	folios  []string  // Folio names last fetched, or nil once they might have changed
+	fetched time.Time // When folios were fetched
+}
+
+// folioNames returns the sender's folio names, only fetching them if they were not fetched recently
+func (s *sender) folioNames() ([]string, error) {
+	if s.folios != nil && time.Since(s.fetched) < folioCacheFor {
+		return s.folios, nil
+	}
+
+	folios, err := s.client.GetFolios()
+	if err != nil {
+		return nil, err
+	}
+	s.rememberFolios(folios)
+	return folios, nil
+}
+
+// rememberFolios caches folio names just fetched, or forgets them if folios is nil
+func (s *sender) rememberFolios(folios []string) {
+	s.folios, s.fetched = folios, time.Now()
+}
+
+// senders returns everyone allowed to text the client by their number. The single clientNumber
+// of older configs is treated as a user with the top-level appenedToken.
+func senders(config Config) map[string]*sender {
+	users := config.Users
+	if config.ClientNumber != "" {
+		users = append([]User{{Number: config.ClientNumber}}, users...)
+	}
+
+	byNumber := map[string]*sender{}
+	for _, user := range users {
+		token := user.AppenedToken
+		if token == "" {
+			token = config.AppenedToken
+		}
+		byNumber[user.Number] = &sender{User: user, client: appendedGo.New(token, config.AppenedURL).OnBehalfOf(user.Number), mu: &sync.Mutex{}}
+	}
+	return byNumber
+}
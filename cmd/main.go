@@ -1,293 +1,290 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"net/http"
+	"net"
 	"os"
-	"regexp"
+	"os/signal"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/appened/HTTPLogger"
+	"github.com/appened/audit"
 	"github.com/appened/note"
-	"github.com/gorilla/mux"
+	"github.com/appened/server"
 )
 
-// TODO: Add surfacing a note
-
 func main() {
-	// Init Logger
-	logger := HTTPLogger.New(os.Stdout, HTTPLogger.LOG_ALL)
-
-	// Load Folios
-	logger.Info("Loading folios")
-	folios, err := note.LoadFolios()
-	if err != nil {
-		logger.Error(err)
+	// `audit-verify [path]` checks the audit log's hash chain instead of serving
+	if len(os.Args) > 1 && os.Args[1] == "audit-verify" {
+		os.Exit(verifyAudit(os.Args[2:]))
 	}
-	logger.Info(fmt.Sprintf("Loaded %d folios\n", len(folios)))
 
-	r := mux.NewRouter()
+	// Init Logger, writing text unless APPENED_LOG_FORMAT asks for json or logfmt, to stdout unless APPENED_LOG_FILE is set
+	format, formatErr := HTTPLogger.ParseFormat(os.Getenv("APPENED_LOG_FORMAT"))
+	fileOptions, optionsErr := logFileOptions()
+	sinks, sinksErr := HTTPLogger.Sinks{Default: os.Stdout}, error(nil)
+	if optionsErr == nil {
+		sinks, sinksErr = fileOptions.Open(os.Stdout)
+	}
+	defer sinks.Close()
 
-	// Add middleware
-	initailizeMiddleware(r, logger)
+	logger := HTTPLogger.NewWithSinks(sinks, HTTPLogger.LOG_ALL, format)
+	for _, err := range []error{formatErr, optionsErr, sinksErr} {
+		if err != nil {
+			logger.Error(err)
+			os.Exit(1)
+		}
+	}
 
-	// Set up routes
-	initailizeRoutes(r, logger, folios)
+	// Log everything unless APPENED_LOG_LEVEL asks for less. The level can be changed while running through admin/log-level
+	if level := os.Getenv("APPENED_LOG_LEVEL"); level != "" {
+		if err := logger.SetLevel(level); err != nil {
+			logger.Error(err)
+			os.Exit(1)
+		}
+	}
 
-	// Start Server
-	logger.Info("Listening on 8081")
-	http.ListenAndServe(":8081", r)
-}
+	// How requests are logged: structured (default), common, combined, or off
+	accessLog, err := HTTPLogger.ParseAccessFormat(os.Getenv("APPENED_ACCESS_LOG"))
+	if err != nil {
+		logger.Error(err)
+		os.Exit(1)
+	}
 
-// Intialize routes
-func initailizeRoutes(router *mux.Router, logger *HTTPLogger.Logger, folios map[string]*note.Folio) {
-	// GET folios/{name}: Get a folio's notes in an array of strings
-	router.HandleFunc("/folios/{name}{slash:/?}", func(w http.ResponseWriter, r *http.Request) {
-		name := mux.Vars(r)["name"]
-
-		folio := folios[name]
-		if folio == nil {
-			w.WriteHeader(http.StatusNotFound)
-			logger.InfoHTTP(r, http.StatusNotFound)
-			return
+	// How long to wait for in-flight requests when asked to stop
+	shutdownTimeout := 10 * time.Second
+	if timeout := os.Getenv("APPENED_SHUTDOWN_TIMEOUT"); timeout != "" {
+		if shutdownTimeout, err = time.ParseDuration(timeout); err != nil {
+			logger.Error(fmt.Errorf("Invalid APPENED_SHUTDOWN_TIMEOUT: %w", err))
+			os.Exit(1)
 		}
+	}
 
-		var notes []string
-		for _, note := range folio.Notes {
-			notes = append(notes, note.ListString())
-		}
-		jsonResponse, err := json.Marshal(notes)
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			logger.ApplicationError(r, err)
-			return
-		}
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		w.Write(jsonResponse)
-		logger.InfoHTTP(r, http.StatusOK)
-	}).Methods("GET")
-
-	// POST folios/{name} Append a note to a folio
-	router.HandleFunc("/folios/{name}{slash:/?}", func(w http.ResponseWriter, r *http.Request) {
-		name := mux.Vars(r)["name"]
-
-		if err := r.ParseForm(); err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			logger.ApplicationError(r, err)
-			return
+	// How long web UI sessions last
+	var sessionTTL time.Duration
+	if ttl := os.Getenv("APPENED_SESSION_TTL"); ttl != "" {
+		if sessionTTL, err = time.ParseDuration(ttl); err != nil {
+			logger.Error(fmt.Errorf("Invalid APPENED_SESSION_TTL: %w", err))
+			os.Exit(1)
 		}
+	}
 
-		folio := folios[name]
-		if folio == nil {
-			w.WriteHeader(http.StatusNotFound)
-			logger.InfoHTTP(r, http.StatusNotFound)
-			return
-		}
+	// Record changes in APPENED_AUDIT_LOG, or audit.jsonl in the data directory
+	auditLog, err := audit.Open(auditPath())
+	if err != nil {
+		logger.Error(err)
+		os.Exit(1)
+	}
+	defer auditLog.Close()
+	for _, repair := range auditLog.Repairs() {
+		logger.Warn("Audit log damaged: " + repair + ", run audit-verify to check its chain")
+	}
 
-		err := folio.Append(r.FormValue("note"))
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			logger.ApplicationError(r, err)
-			return
-		}
+	// Limit how often clients may make requests
+	rateLimit, err := rateLimitConfig()
+	if err != nil {
+		logger.Error(err)
+		os.Exit(1)
+	}
 
-		w.WriteHeader(http.StatusCreated)
-		logger.InfoHTTP(r, http.StatusCreated)
-		logger.Info(fmt.Sprintf("Created note in folio %v\n", name))
-	}).Methods("POST")
+	// Serve over TLS if APPENED_TLS_CERT and APPENED_TLS_KEY are set, accepting client certificates as well as the token
+	auth := server.TokenAuth(server.Token{Name: "default", Secret: os.Getenv("APPENED_AUTH_TOKEN")})
+	tlsConfig, clientCerts, err := tlsConfigFromEnv()
+	if err != nil {
+		logger.Error(err)
+		os.Exit(1)
+	}
+	if len(clientCerts) > 0 {
+		auth = server.AnyAuth(auth, server.CertAuth(clientCerts...))
+	}
 
-	// PUT folios/{name}/i Append a note to a folio
-	router.HandleFunc("/folios/{name}{slash:/?}", func(w http.ResponseWriter, r *http.Request) {
-		name := mux.Vars(r)["name"]
-		indexString := mux.Vars(r)["index"]
+	// Listen on a socket from systemd if socket activated, otherwise on APPENED_ADDR: a TCP address, or unix: and a socket path
+	listeners, err := server.SystemdListeners()
+	if err != nil {
+		logger.Error(err)
+		os.Exit(1)
+	}
+	var listener net.Listener
+	if len(listeners) > 0 {
+		listener = listeners[0]
+	}
 
-		index, err := strconv.Atoi(indexString)
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			logger.ApplicationError(r, err)
-			return
-		}
+	addr := ":8081"
+	if value := os.Getenv("APPENED_ADDR"); value != "" {
+		addr = value
+	}
 
-		if err := r.ParseForm(); err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			logger.ApplicationError(r, err)
-			return
+	var socketMode uint64
+	if value := os.Getenv("APPENED_SOCKET_MODE"); value != "" {
+		if socketMode, err = strconv.ParseUint(value, 8, 32); err != nil {
+			logger.Error(fmt.Errorf("Invalid APPENED_SOCKET_MODE, must be octal like 0660: %w", err))
+			os.Exit(1)
 		}
+	}
 
-		folio := folios[name]
-		if folio == nil {
-			w.WriteHeader(http.StatusNotFound)
-			logger.InfoHTTP(r, http.StatusNotFound)
-			return
-		}
+	// Init Server
+	srv, err := server.New(server.Config{
+		Addr:            addr,
+		Listener:        listener,
+		SocketMode:      os.FileMode(socketMode),
+		Store:           note.DefaultStore,
+		Logger:          logger,
+		Auth:            auth,
+		AccessLog:       accessLog,
+		Audit:           auditLog,
+		RateLimit:       rateLimit,
+		TLS:             tlsConfig,
+		SessionTTL:      sessionTTL,
+		ShutdownTimeout: shutdownTimeout,
+	})
+	if err != nil {
+		logger.Error(err)
+		os.Exit(1)
+	}
 
-		err = folio.Edit(index, r.FormValue("note"))
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			logger.ApplicationError(r, err)
-			return
-		}
+	// Serve until interrupted or stopped, e.g. by `docker stop`
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-		w.WriteHeader(http.StatusCreated)
-		logger.InfoHTTP(r, http.StatusCreated)
-		logger.Info(fmt.Sprintf("Edited note %v in folio %v\n", index, name))
-	}).Methods("PUT")
+	if err = srv.Run(ctx); err != nil {
+		logger.Error(err)
+		auditLog.Close()
+		sinks.Close()
+		os.Exit(1)
+	}
+	logger.Info("Shut down cleanly")
+}
 
-	// GET folios/{name}/{index}/done Toggle done on note
-	router.HandleFunc("/folios/{name}/{index}/done{slash:/?}", func(w http.ResponseWriter, r *http.Request) {
-		name := mux.Vars(r)["name"]
-		indexString := mux.Vars(r)["index"]
+// logFileOptions reads where logs are written, and how log files are rotated, from the environment
+func logFileOptions() (HTTPLogger.FileOptions, error) {
+	options := HTTPLogger.FileOptions{
+		Path:        os.Getenv("APPENED_LOG_FILE"),
+		ErrorPath:   os.Getenv("APPENED_LOG_ERROR_FILE"),
+		RotateEvery: os.Getenv("APPENED_LOG_ROTATE_EVERY"),
+		MaxAge:      os.Getenv("APPENED_LOG_MAX_AGE"),
+	}
 
-		index, err := strconv.Atoi(indexString)
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			logger.ApplicationError(r, err)
-			return
+	var err error
+	if value := os.Getenv("APPENED_LOG_MAX_SIZE_MB"); value != "" {
+		if options.MaxSizeMB, err = strconv.Atoi(value); err != nil {
+			return options, fmt.Errorf("Invalid APPENED_LOG_MAX_SIZE_MB: %w", err)
 		}
-
-		folio := folios[name]
-		if folio == nil {
-			w.WriteHeader(http.StatusNotFound)
-			logger.InfoHTTP(r, http.StatusNotFound)
-			return
+	}
+	if value := os.Getenv("APPENED_LOG_MAX_BACKUPS"); value != "" {
+		if options.MaxBackups, err = strconv.Atoi(value); err != nil {
+			return options, fmt.Errorf("Invalid APPENED_LOG_MAX_BACKUPS: %w", err)
 		}
-
-		if err = folio.ToggleDone(index); err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			logger.Error(err)
-			return
+	}
+	if value := os.Getenv("APPENED_LOG_COMPRESS"); value != "" {
+		if options.Compress, err = strconv.ParseBool(value); err != nil {
+			return options, fmt.Errorf("Invalid APPENED_LOG_COMPRESS: %w", err)
 		}
+	}
 
-		w.WriteHeader(http.StatusCreated)
-		logger.InfoHTTP(r, http.StatusCreated)
-		logger.Info(fmt.Sprintf("Toggled done on note %v in folio %v\n", index, name))
-	}).Methods("GET")
-
-	// POST folios/ Create a folio
-	router.HandleFunc("/folios{slash:/?}", func(w http.ResponseWriter, r *http.Request) {
-		// Get folio name from request
-		if err := r.ParseForm(); err != nil {
-			logger.ApplicationError(r, err)
-			return
-		}
-		name := r.FormValue("name")
-		logger.Debug(name)
+	return options, nil
+}
 
-		// Validation
-		matched, err := regexp.MatchString(`^[a-zA-Z]+$`, name)
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			logger.ApplicationError(r, err)
-			return
-		}
-		if !matched {
-			w.WriteHeader(http.StatusBadRequest)
-			fmt.Fprintf(w, "Invalid folio name, must be one word")
-			logger.InfoHTTP(r, http.StatusBadRequest)
-			return
+// rateLimitConfig reads rate limits from the environment. By default each address and each token may
+// make 10 requests a second in bursts of 50, and addresses are locked out for 15 minutes after 10 failed
+// authentications.
+func rateLimitConfig() (server.RateLimitConfig, error) {
+	config := server.RateLimitConfig{
+		PerIP:        server.Rate{PerSecond: 10, Burst: 50},
+		PerToken:     server.Rate{PerSecond: 10, Burst: 50},
+		LockoutAfter: 10,
+		LockoutFor:   15 * time.Minute,
+	}
+
+	var err error
+	if value := os.Getenv("APPENED_RATE_LIMIT_IP"); value != "" {
+		if config.PerIP, err = server.ParseRate(value); err != nil {
+			return config, fmt.Errorf("Invalid APPENED_RATE_LIMIT_IP: %w", err)
 		}
-		for _, f := range folios {
-			if f.Name == name {
-				w.WriteHeader(http.StatusBadRequest)
-				fmt.Fprintf(w, "Folio with name exists, try a different name")
-				logger.InfoHTTP(r, http.StatusBadRequest)
-				return
-			}
+	}
+	if value := os.Getenv("APPENED_RATE_LIMIT_TOKEN"); value != "" {
+		if config.PerToken, err = server.ParseRate(value); err != nil {
+			return config, fmt.Errorf("Invalid APPENED_RATE_LIMIT_TOKEN: %w", err)
 		}
-
-		// Create New Folio
-		folio, err := note.CreateFolio(name)
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			logger.ApplicationError(r, err)
-			return
+	}
+	if value := os.Getenv("APPENED_LOCKOUT_AFTER"); value != "" {
+		if config.LockoutAfter, err = strconv.Atoi(value); err != nil {
+			return config, fmt.Errorf("Invalid APPENED_LOCKOUT_AFTER: %w", err)
 		}
-		folios[name] = folio
-
-		w.WriteHeader(http.StatusCreated)
-		logger.InfoHTTP(r, http.StatusCreated)
-		logger.Info(fmt.Sprintf("Created folio named %v\n", name))
-	}).Methods("POST")
-
-	// GET folios/ List all folio names
-	router.HandleFunc("/folios{slash:/?}", func(w http.ResponseWriter, r *http.Request) {
-		folioNames := []string{}
-		for _, folio := range folios {
-			folioNames = append(folioNames, folio.Name)
+	}
+	if value := os.Getenv("APPENED_LOCKOUT_FOR"); value != "" {
+		if config.LockoutFor, err = time.ParseDuration(value); err != nil {
+			return config, fmt.Errorf("Invalid APPENED_LOCKOUT_FOR: %w", err)
 		}
+	}
+	if value := os.Getenv("APPENED_TRUSTED_PROXIES"); value != "" {
+		config.TrustedProxies = strings.Split(value, ",")
+	}
 
-		jsonResponse, err := json.Marshal(folioNames)
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			logger.ApplicationError(r, err)
-			return
-		}
+	return config, nil
+}
 
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		w.Write(jsonResponse)
-		logger.InfoHTTP(r, http.StatusOK)
-	}).Methods("GET")
-
-	// DELETE folios/{name}
-	router.HandleFunc("/folios/{name}{slash:/?}", func(w http.ResponseWriter, r *http.Request) {
-		name := mux.Vars(r)["name"]
-
-		folio, ok := folios[name]
-		if !ok {
-			w.WriteHeader(http.StatusNotFound)
-			logger.InfoHTTP(r, http.StatusNotFound)
-			return
-		}
+// tlsConfigFromEnv reads TLS settings from the environment, returning nil if TLS is not configured.
+// APPENED_TLS_CLIENT_CERTS names a JSON file listing the client certificates that map to identities.
+func tlsConfigFromEnv() (*server.TLSConfig, []server.ClientCert, error) {
+	certFile, keyFile := os.Getenv("APPENED_TLS_CERT"), os.Getenv("APPENED_TLS_KEY")
+	if certFile == "" && keyFile == "" {
+		return nil, nil, nil
+	}
 
-		if err := folio.Delete(); err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			logger.ApplicationError(r, err)
-			return
+	config := &server.TLSConfig{
+		CertFile:     certFile,
+		KeyFile:      keyFile,
+		ClientCAFile: os.Getenv("APPENED_TLS_CLIENT_CA"),
+	}
 
+	var err error
+	if value := os.Getenv("APPENED_TLS_REQUIRE_CLIENT_CERT"); value != "" {
+		if config.RequireClientCert, err = strconv.ParseBool(value); err != nil {
+			return nil, nil, fmt.Errorf("Invalid APPENED_TLS_REQUIRE_CLIENT_CERT: %w", err)
 		}
+	}
 
-		delete(folios, name)
-
-		w.WriteHeader(http.StatusOK)
-		logger.InfoHTTP(r, http.StatusOK)
-		logger.Info(fmt.Sprintf("Deleted folio %v\n", name))
-	}).Methods("DELETE")
+	clientCerts := []server.ClientCert{}
+	if path := os.Getenv("APPENED_TLS_CLIENT_CERTS"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err = json.Unmarshal(data, &clientCerts); err != nil {
+			return nil, nil, fmt.Errorf("Invalid APPENED_TLS_CLIENT_CERTS file: %w", err)
+		}
+	}
 
-	// Manually reset 404 middleware or it will not fire. Custom 404 also ensures logging
-	router.NotFoundHandler = router.NewRoute().HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusNotFound)
-		logger.InfoHTTP(r, http.StatusNotFound)
-	}).GetHandler()
+	return config, clientCerts, nil
+}
 
+// auditPath returns where the audit log is kept
+func auditPath() string {
+	if path := os.Getenv("APPENED_AUDIT_LOG"); path != "" {
+		return path
+	}
+	return filepath.Join(note.DefaultStore.Dir, "audit.jsonl")
 }
 
-// Initializes Application Middleware
-func initailizeMiddleware(router *mux.Router, logger *HTTPLogger.Logger) {
-	// Authentication middleware
-	router.Use(func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Get app token
-			token := os.Getenv("APPENED_AUTH_TOKEN")
-
-			// Get client token
-			reqToken := r.Header.Get("Authorization")
-			splitToken := strings.Split(reqToken, "Bearer ")
-
-			// Check if token was provided and if it is valid
-			authorized := len(splitToken) == 2 && splitToken[1] == token
-
-			// Authenticate user
-			if authorized {
-				next.ServeHTTP(w, r)
-			} else {
-				// Reject unauthorized requests
-				w.WriteHeader(http.StatusUnauthorized)
-				logger.InfoHTTP(r, http.StatusUnauthorized)
-			}
-		})
-	})
+// verifyAudit checks the hash chain of the audit log at args[0], or the configured audit log, and returns the exit status
+func verifyAudit(args []string) int {
+	path := auditPath()
+	if len(args) > 0 {
+		path = args[0]
+	}
+
+	count, err := audit.VerifyFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v: %v entries verified, then: %v\n", path, count, err)
+		return 1
+	}
+
+	fmt.Printf("%v: all %v entries verified\n", path, count)
+	return 0
 }
@@ -2,7 +2,7 @@ if [ $1 = "run" ]
 then
 	if [ $2 = "appened" ]
 		then
-			docker stop appened
+			docker stop --time 15 appened
 			docker rm appened
 			docker run -d -v $PWD/data:/data --env-file .env --name appened -p 8082:8081 appened:latest
 		elif [ $2 = "appened-twilio" ]
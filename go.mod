@@ -1,8 +1,21 @@
 module github.com/appened
 
-go 1.17
+go 1.21
 
 require (
+	github.com/gdamore/tcell/v2 v2.8.1
 	github.com/gorilla/mux v1.8.0
+	github.com/mattn/go-runewidth v0.0.16
 	github.com/twilio/twilio-go v0.18.0
 )
+
+require (
+	github.com/gdamore/encoding v1.0.1 // indirect
+	github.com/golang/mock v1.6.0 // indirect
+	github.com/lucasb-eyer/go-colorful v1.2.0 // indirect
+	github.com/pkg/errors v0.9.1 // indirect
+	github.com/rivo/uniseg v0.4.3 // indirect
+	golang.org/x/sys v0.29.0 // indirect
+	golang.org/x/term v0.28.0 // indirect
+	golang.org/x/text v0.21.0 // indirect
+)
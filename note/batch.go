@@ -0,0 +1,75 @@
+package note
+
+import (
+	"fmt"
+	"time"
+)
+
+// OpKind identifies the change an Operation makes to a folio
+type OpKind string
+
+const (
+	OpAppend     OpKind = "append"
+	OpEdit       OpKind = "edit"
+	OpToggleDone OpKind = "done"
+)
+
+// Operation is a single change applied to a folio as part of a batch
+type Operation struct {
+	Kind  OpKind // Kind of change to make
+	Index int    // Index of the note to change, unused for appends
+	Text  string // Text of the note, unused when toggling done
+}
+
+// BatchError reports which operation caused a batch to be rejected
+type BatchError struct {
+	Op  int   // Position of the failed operation in the batch
+	Err error // Reason the operation failed
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("operation %v: %v", e.Op, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// Batch applies the operations to the folio in order and writes it to disk once.
+// Either every operation is applied or, if any of them fails, none are.
+func (f *Folio) Batch(ops []Operation) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	notes := make([]Note, len(f.Notes), len(f.Notes)+len(ops))
+	copy(notes, f.Notes)
+
+	now := time.Now().Unix()
+	for i, op := range ops {
+		switch op.Kind {
+		case OpAppend:
+			notes = append(notes, Note{len(notes), false, op.Text, now, now, now})
+		case OpEdit:
+			if err := checkIndex(op.Index, len(notes)); err != nil {
+				return &BatchError{i, err}
+			}
+			notes[op.Index].Edit(op.Text)
+		case OpToggleDone:
+			if err := checkIndex(op.Index, len(notes)); err != nil {
+				return &BatchError{i, err}
+			}
+			notes[op.Index].ToggleDone()
+		default:
+			return &BatchError{i, fmt.Errorf("Unknown operation %q", op.Kind)}
+		}
+	}
+
+	previous := f.Notes
+	f.Notes = notes
+	if err := f.write(); err != nil {
+		f.Notes = previous
+		return err
+	}
+
+	return nil
+}
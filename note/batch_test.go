@@ -0,0 +1,191 @@
+package note
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// testFolio creates a folio in a new store, appending a note for each of texts
+func testFolio(t *testing.T, texts ...string) (*Store, *Folio) {
+	t.Helper()
+
+	store := NewStore(t.TempDir())
+	folio, err := store.Create("groceries")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, text := range texts {
+		if _, err := folio.Append(text); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return store, folio
+}
+
+// summary describes notes as their text, followed by a tick if they are done
+func summary(notes []Note) []string {
+	list := []string{}
+	for _, n := range notes {
+		if n.Done {
+			list = append(list, n.Text+" ✅")
+		} else {
+			list = append(list, n.Text)
+		}
+	}
+	return list
+}
+
+// onDisk returns the summary of the named folio as it is read back from store
+func onDisk(t *testing.T, store *Store, name string) []string {
+	t.Helper()
+
+	folios, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if folios[name] == nil {
+		return nil
+	}
+	return summary(folios[name].List())
+}
+
+func TestBatch(t *testing.T) {
+	tests := []struct {
+		name string
+		ops  []Operation
+		want []string
+		fail int // Position of the operation that fails, or -1 if the batch succeeds
+	}{
+		{
+			name: "every kind of operation",
+			ops: []Operation{
+				{Kind: OpAppend, Text: "bread"},
+				{Kind: OpEdit, Index: 0, Text: "oat milk"},
+				{Kind: OpToggleDone, Index: 1},
+			},
+			want: []string{"oat milk", "eggs ✅", "bread"},
+			fail: -1,
+		},
+		{
+			name: "operations see earlier ones",
+			ops: []Operation{
+				{Kind: OpAppend, Text: "bread"},
+				{Kind: OpToggleDone, Index: 2},
+				{Kind: OpEdit, Index: 2, Text: "rye bread"},
+			},
+			want: []string{"milk", "eggs", "rye bread ✅"},
+			fail: -1,
+		},
+		{
+			name: "empty",
+			want: []string{"milk", "eggs"},
+			fail: -1,
+		},
+		{
+			name: "index too big",
+			ops: []Operation{
+				{Kind: OpAppend, Text: "bread"},
+				{Kind: OpToggleDone, Index: 3},
+			},
+			want: []string{"milk", "eggs"},
+			fail: 1,
+		},
+		{
+			name: "negative index",
+			ops:  []Operation{{Kind: OpEdit, Index: -1, Text: "bread"}},
+			want: []string{"milk", "eggs"},
+			fail: 0,
+		},
+		{
+			name: "unknown operation",
+			ops: []Operation{
+				{Kind: OpToggleDone, Index: 0},
+				{Kind: OpToggleDone, Index: 1},
+				{Kind: "delete", Index: 0},
+			},
+			want: []string{"milk", "eggs"},
+			fail: 2,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store, folio := testFolio(t, "milk", "eggs")
+
+			err := folio.Batch(test.ops)
+			batchErr := &BatchError{}
+			switch {
+			case test.fail < 0 && err != nil:
+				t.Fatalf("Batch failed: %v", err)
+			case test.fail >= 0 && !errors.As(err, &batchErr):
+				t.Fatalf("Got error %v, want a *BatchError", err)
+			case test.fail >= 0 && batchErr.Op != test.fail:
+				t.Errorf("Operation %v failed, want %v", batchErr.Op, test.fail)
+			}
+
+			// A failed batch changes nothing, in memory or on disk
+			if got := summary(folio.List()); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Folio has notes %q, want %q", got, test.want)
+			}
+			if got := onDisk(t, store, "groceries"); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Folio on disk has notes %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestConcurrentChanges(t *testing.T) {
+	store, folio := testFolio(t, "milk")
+
+	// Batches, single changes and reads all take the folio's lock, so none are lost or torn
+	const workers, changes = 4, 20
+	wg := &sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(3)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < changes; j++ {
+				ops := []Operation{{Kind: OpAppend, Text: fmt.Sprintf("batch %v %v", i, j)}, {Kind: OpToggleDone, Index: 0}}
+				if err := folio.Batch(ops); err != nil {
+					t.Error(err)
+				}
+			}
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < changes; j++ {
+				if _, err := folio.Append(fmt.Sprintf("append %v %v", i, j)); err != nil {
+					t.Error(err)
+				}
+				if err := folio.Edit(0, "milk"); err != nil {
+					t.Error(err)
+				}
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < changes; j++ {
+				for i, n := range folio.List() {
+					if n.Index() != i {
+						t.Errorf("Note %v has index %v", i, n.Index())
+					}
+				}
+				if _, err := folio.Note(0); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	want := 1 + 2*workers*changes
+	if got := len(folio.List()); got != want {
+		t.Errorf("Folio has %v notes, want %v", got, want)
+	}
+	if got := onDisk(t, store, "groceries"); !reflect.DeepEqual(got, summary(folio.List())) {
+		t.Errorf("Folio on disk differs from memory:\n%q\nwant:\n%q", got, summary(folio.List()))
+	}
+}
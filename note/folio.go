@@ -3,10 +3,7 @@ package note
 import (
 	"encoding/csv"
 	"errors"
-	"fmt"
 	"os"
-	"strconv"
-	"strings"
 	"sync"
 	"time"
 )
@@ -17,171 +14,159 @@ type Folio struct {
 	Notes    []Note
 	filename string
 	mu       *sync.RWMutex
+	store    *Store // Store the folio is kept in
 }
 
 // LoadFolios reads in folios from `data/`
 func LoadFolios() (map[string]*Folio, error) {
-	// Get file names
-	files, err := os.ReadDir("../data/")
-	if err != nil {
-		return nil, err
-	}
+	return DefaultStore.Load()
+}
 
-	// Init folios
-	folios := map[string]*Folio{}
+// CreateFolio creates a new folio in `data/`, and writes it to disk
+func CreateFolio(name string) (*Folio, error) {
+	return DefaultStore.Create(name)
+}
 
-	// Fetch each folio
-	for _, file := range files {
-		filename := file.Name()
-		folio, err := parseFolioCSV(filename)
-		if err != nil {
-			return nil, err
-		}
-		name := strings.Split(filename, ".")[0]
-		folios[name] = folio
-	}
+// Append appends a Note to the Folio, writes it to disk, and returns the new note
+func (f *Folio) Append(text string) (n Note, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
 
-	return folios, nil
-}
+	start := time.Now()
+	defer func() { f.store.observe("append", start, err) }()
 
-func parseFolioCSV(filename string) (*Folio, error) {
-	// Open file for reading
-	filePath := fmt.Sprintf("../data/%s", filename)
-	file, err := os.Open(filePath)
+	now := time.Now().Unix()
+	n = Note{len(f.Notes), false, text, now, now, now}
+
+	file, err := os.OpenFile(f.filename, os.O_WRONLY|os.O_APPEND, 0644)
 	if err != nil {
-		return nil, err
+		return Note{}, err
 	}
 	defer file.Close()
 
-	// Get folio's name
-	name := filename[:len(filename)-4]
-
-	// Get all records as strings
-	csvReader := csv.NewReader(file)
-	records, err := csvReader.ReadAll()
-	if err != nil {
-		return nil, err
-	}
-
-	// Create Notes from csv string records
-	notes := []Note{}
-	for i, record := range records {
-		note := Note{}
-		note.index = i
-		note.Text = record[0]
-		note.Done, err = strconv.ParseBool(record[1])
-		if err != nil {
-			return nil, err
-		}
-		note.DateCreated, err = strconv.ParseInt(record[2], 10, 64)
-		if err != nil {
-			return nil, err
-		}
-		note.DateDone, err = strconv.ParseInt(record[3], 10, 64)
-		if err != nil {
-			return nil, err
-		}
-		note.DateEdited, err = strconv.ParseInt(record[4], 10, 64)
-		if err != nil {
-			return nil, err
-		}
-		notes = append(notes, note)
+	writer := csv.NewWriter(file)
+	if err = writer.Write(n.csvLine()); err != nil {
+		return Note{}, err
 	}
+	writer.Flush()
+	if err = writer.Error(); err != nil {
+		return Note{}, err
+	}
+	f.Notes = append(f.Notes, n)
 
-	// Create folio
-	folio := Folio{name, notes, filePath, &sync.RWMutex{}}
-
-	return &folio, nil
+	return n, nil
 }
 
-// CreateFolio creates a new folio, and writes it to disk
-func CreateFolio(name string) (*Folio, error) {
-	fileName := fmt.Sprintf("../data/%s.csv", name)
-	mu := &sync.RWMutex{}
+// Note returns a copy of the note at index
+func (f *Folio) Note(index int) (Note, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
 
-	file, err := os.OpenFile(fileName, os.O_CREATE|os.O_EXCL, 0666)
-	if err != nil {
-		return nil, err
+	if err := checkIndex(index, len(f.Notes)); err != nil {
+		return Note{}, err
 	}
-	file.Close()
 
-	f := &Folio{name, []Note{}, fileName, mu}
+	return f.Notes[index], nil
+}
 
-	return f, nil
+// List returns a copy of every note in the folio
+func (f *Folio) List() []Note {
+	return f.snapshot()
 }
 
-// Append appends a Note to the Folio and writes it to disk
-func (f *Folio) Append(note string) error {
+// ToggleDone will toggle Done between true and false
+func (f *Folio) ToggleDone(index int) error {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 
-	now := time.Now().Unix()
-	n := Note{len(f.Notes), false, note, now, now, now}
-
-	file, err := os.OpenFile(f.filename, os.O_WRONLY|os.O_APPEND, 0644)
-	if err != nil {
+	if err := checkIndex(index, len(f.Notes)); err != nil {
 		return err
 	}
-	defer file.Close()
-
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
 
-	if err = writer.Write(n.csvLine()); err != nil {
-		return err
-	}
-	f.Notes = append(f.Notes, n)
+	f.Notes[index].ToggleDone()
 
-	return nil
+	return f.write()
 }
 
-// ToggleDone will toggle Done between true and false
-func (f *Folio) ToggleDone(index int) error {
+// SetDone marks a note as done or not done. Setting it to its current value changes nothing.
+func (f *Folio) SetDone(index int, done bool) error {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 
-	if index >= len(f.Notes) {
-		return errors.New("Index too big")
-	}
-	if index < 0 {
-		return errors.New("Index must be positive")
+	if err := checkIndex(index, len(f.Notes)); err != nil {
+		return err
 	}
 
+	if f.Notes[index].Done == done {
+		return nil
+	}
 	f.Notes[index].ToggleDone()
 
-	file, err := os.OpenFile(f.filename, os.O_WRONLY|os.O_APPEND, 0644)
-	if err != nil {
+	return f.write()
+}
+
+// Edit edits the contents of a note
+func (f *Folio) Edit(index int, text string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := checkIndex(index, len(f.Notes)); err != nil {
 		return err
 	}
-	defer file.Close()
 
-	file.Truncate(0)
+	f.Notes[index].Edit(text)
 
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
+	return f.write()
+}
 
-	for _, n := range f.Notes {
-		if err = writer.Write(n.csvLine()); err != nil {
-			return err
-		}
+// Delete will remove the folio's csv from disk
+func (f *Folio) Delete() (err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	start := time.Now()
+	defer func() { f.store.observe("delete", start, err) }()
+
+	if err := os.Remove(f.filename); err != nil {
+		return err
 	}
 
 	return nil
 }
 
-// Edit edits the contents of a note
-func (f *Folio) Edit(index int, text string) error {
-	f.mu.Lock()
-	defer f.mu.Unlock()
-
-	if index >= len(f.Notes) {
+// checkIndex ensures index refers to one of count notes
+func checkIndex(index int, count int) error {
+	if index >= count {
 		return errors.New("Index too big")
 	}
 	if index < 0 {
 		return errors.New("Index must be positive")
 	}
+	return nil
+}
+
+// Sync waits for any write in progress to finish, then flushes the folio's csv to stable storage
+func (f *Folio) Sync() (err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	start := time.Now()
+	defer func() { f.store.observe("sync", start, err) }()
 
-	f.Notes[index].Text = text
+	file, err := os.OpenFile(f.filename, os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return file.Sync()
+}
+
+// write truncates the folio's csv and writes every note back to disk.
+// The caller must hold the folio's lock.
+func (f *Folio) write() (err error) {
+	start := time.Now()
+	defer func() { f.store.observe("write", start, err) }()
 
 	file, err := os.OpenFile(f.filename, os.O_WRONLY|os.O_APPEND, 0644)
 	if err != nil {
@@ -189,25 +174,42 @@ func (f *Folio) Edit(index int, text string) error {
 	}
 	defer file.Close()
 
-	file.Truncate(0)
+	if err = file.Truncate(0); err != nil {
+		return err
+	}
 
 	writer := csv.NewWriter(file)
-	defer writer.Flush()
-
 	for _, n := range f.Notes {
 		if err = writer.Write(n.csvLine()); err != nil {
 			return err
 		}
 	}
-	return nil
+	writer.Flush()
+
+	return writer.Error()
 }
 
-// Delete will remove the folio's csv from disk
-func (f *Folio) Delete() error {
+// snapshot returns a copy of the folio's notes
+func (f *Folio) snapshot() []Note {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	notes := make([]Note, len(f.Notes))
+	copy(notes, f.Notes)
+
+	return notes
+}
+
+// restore replaces the folio's notes and writes them to disk
+func (f *Folio) restore(notes []Note) error {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 
-	if err := os.Remove(f.filename); err != nil {
+	previous := f.Notes
+	f.Notes = make([]Note, len(notes))
+	copy(f.Notes, notes)
+	if err := f.write(); err != nil {
+		f.Notes = previous
 		return err
 	}
 
@@ -0,0 +1,129 @@
+package note
+
+import (
+	"errors"
+	"sync"
+)
+
+var (
+	// ErrNothingToUndo is returned when a folio has no changes left to undo
+	ErrNothingToUndo = errors.New("Nothing to undo")
+	// ErrNothingToRedo is returned when a folio has no undone changes left to redo
+	ErrNothingToRedo = errors.New("Nothing to redo")
+)
+
+// revision is the state of a folio before a change was made to it
+type revision struct {
+	op     string // Name of the change, e.g. append
+	exists bool   // Whether the folio existed
+	notes  []Note // The folio's notes
+}
+
+// History keeps a bounded stack of changes to each folio so that they can be undone and redone.
+// Stacks are keyed by folio name, so a deleted folio can be brought back.
+type History struct {
+	store *Store // Store deleted folios are recreated in
+	limit int    // Maximum number of changes remembered per folio
+	mu    *sync.Mutex
+	undo  map[string][]revision
+	redo  map[string][]revision
+}
+
+// NewHistory creates a History remembering up to limit changes per folio, recreating deleted folios in store
+func NewHistory(store *Store, limit int) *History {
+	return &History{store, limit, &sync.Mutex{}, map[string][]revision{}, map[string][]revision{}}
+}
+
+// Track runs change against folio and, if it succeeds, records it as op so that it can be undone.
+// Making a new change clears anything that could have been redone.
+func (h *History) Track(op string, folio *Folio, change func() error) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	before := revision{op, true, folio.snapshot()}
+	if err := change(); err != nil {
+		return err
+	}
+
+	h.undo[folio.Name] = h.push(h.undo[folio.Name], before)
+	delete(h.redo, folio.Name)
+
+	return nil
+}
+
+// Forget drops every change recorded for a folio
+func (h *History) Forget(name string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.undo, name)
+	delete(h.redo, name)
+}
+
+// Undo reverts the most recent change to the named folio, returning the name of the change.
+// folio is the folio's current state, or nil if it has been deleted. Undo returns the folio as it is
+// afterwards: folio itself, a recreated folio if a deletion was undone, or nil if it was deleted.
+// The caller decides which folios it serves, so it is up to it to add or remove the folio.
+func (h *History) Undo(name string, folio *Folio) (string, *Folio, error) {
+	return h.step(name, folio, h.undo, h.redo, ErrNothingToUndo)
+}
+
+// Redo reapplies the most recently undone change to the named folio, returning the name of the
+// change and the folio as it is afterwards, in the same way as Undo.
+func (h *History) Redo(name string, folio *Folio) (string, *Folio, error) {
+	return h.step(name, folio, h.redo, h.undo, ErrNothingToRedo)
+}
+
+// step pops a revision from one stack, restores it, and pushes the state it replaced onto the other
+func (h *History) step(name string, folio *Folio, from map[string][]revision, to map[string][]revision, empty error) (string, *Folio, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	stack := from[name]
+	if len(stack) == 0 {
+		return "", folio, empty
+	}
+	target := stack[len(stack)-1]
+
+	current := revision{op: target.op}
+	if folio != nil {
+		current.exists = true
+		current.notes = folio.snapshot()
+	}
+
+	after := folio
+	switch {
+	case target.exists && folio == nil:
+		created, err := h.store.Create(name)
+		if err != nil {
+			return "", folio, err
+		}
+		if err = created.restore(target.notes); err != nil {
+			return "", folio, err
+		}
+		after = created
+	case target.exists:
+		if err := folio.restore(target.notes); err != nil {
+			return "", folio, err
+		}
+	case folio != nil:
+		if err := folio.Delete(); err != nil {
+			return "", folio, err
+		}
+		after = nil
+	}
+
+	from[name] = stack[:len(stack)-1]
+	to[name] = h.push(to[name], current)
+
+	return target.op, after, nil
+}
+
+// push adds a revision to a stack, dropping the oldest once the stack is over the limit
+func (h *History) push(stack []revision, rev revision) []revision {
+	stack = append(stack, rev)
+	if len(stack) > h.limit {
+		stack = stack[len(stack)-h.limit:]
+	}
+	return stack
+}
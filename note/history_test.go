@@ -0,0 +1,185 @@
+package note
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// track makes a change to folio through history, failing the test if it can't be made
+func track(t *testing.T, history *History, op string, folio *Folio, change func() error) {
+	t.Helper()
+
+	if err := history.Track(op, folio, change); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// appendNote returns a change appending text to folio
+func appendNote(folio *Folio, text string) func() error {
+	return func() error {
+		_, err := folio.Append(text)
+		return err
+	}
+}
+
+func TestUndoRedo(t *testing.T) {
+	store, folio := testFolio(t, "milk")
+	history := NewHistory(store, 20)
+
+	track(t, history, "append", folio, appendNote(folio, "eggs"))
+	track(t, history, "done", folio, func() error { return folio.ToggleDone(0) })
+
+	steps := []struct {
+		redo bool
+		op   string
+		want []string
+	}{
+		{false, "done", []string{"milk", "eggs"}},
+		{false, "append", []string{"milk"}},
+		{true, "append", []string{"milk", "eggs"}},
+		{true, "done", []string{"milk ✅", "eggs"}},
+		{false, "done", []string{"milk", "eggs"}},
+	}
+
+	for i, step := range steps {
+		do := history.Undo
+		if step.redo {
+			do = history.Redo
+		}
+		op, after, err := do("groceries", folio)
+		if err != nil {
+			t.Fatalf("Step %v: %v", i+1, err)
+		}
+		if op != step.op || after != folio {
+			t.Errorf("Step %v stepped %q to folio %p, want %q and %p", i+1, op, after, step.op, folio)
+		}
+		if got := summary(folio.List()); !reflect.DeepEqual(got, step.want) {
+			t.Errorf("Step %v left notes %q, want %q", i+1, got, step.want)
+		}
+		if got := onDisk(t, store, "groceries"); !reflect.DeepEqual(got, step.want) {
+			t.Errorf("Step %v left notes %q on disk, want %q", i+1, got, step.want)
+		}
+	}
+
+	// A new change can't be followed by redoing one undone before it
+	track(t, history, "edit", folio, func() error { return folio.Edit(0, "oat milk") })
+	if _, _, err := history.Redo("groceries", folio); !errors.Is(err, ErrNothingToRedo) {
+		t.Errorf("Redoing after a new change got error %v, want %v", err, ErrNothingToRedo)
+	}
+}
+
+func TestUndoNothing(t *testing.T) {
+	store, folio := testFolio(t, "milk")
+	history := NewHistory(store, 20)
+
+	if _, after, err := history.Undo("groceries", folio); !errors.Is(err, ErrNothingToUndo) || after != folio {
+		t.Errorf("Undoing without changes got %p and error %v, want %p and %v", after, err, folio, ErrNothingToUndo)
+	}
+	if _, _, err := history.Redo("groceries", folio); !errors.Is(err, ErrNothingToRedo) {
+		t.Errorf("Redoing without undoing got error %v, want %v", err, ErrNothingToRedo)
+	}
+
+	// A failed change is not recorded
+	failed := errors.New("Change failed")
+	if err := history.Track("edit", folio, func() error { return failed }); !errors.Is(err, failed) {
+		t.Errorf("Tracking a failed change got error %v, want %v", err, failed)
+	}
+	if _, _, err := history.Undo("groceries", folio); !errors.Is(err, ErrNothingToUndo) {
+		t.Errorf("Undoing a failed change got error %v, want %v", err, ErrNothingToUndo)
+	}
+}
+
+func TestUndoDelete(t *testing.T) {
+	store, folio := testFolio(t, "milk", "eggs")
+	history := NewHistory(store, 20)
+	track(t, history, "delete", folio, folio.Delete)
+
+	// Undoing a delete recreates the folio, with its notes
+	op, restored, err := history.Undo("groceries", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if op != "delete" || restored == nil || restored == folio {
+		t.Fatalf("Undoing delete stepped %q to folio %p, want a new folio", op, restored)
+	}
+	if got := onDisk(t, store, "groceries"); !reflect.DeepEqual(got, []string{"milk", "eggs"}) {
+		t.Errorf("Restored folio has notes %q on disk", got)
+	}
+
+	// Redoing it deletes the folio again
+	op, after, err := history.Redo("groceries", restored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if op != "delete" || after != nil {
+		t.Errorf("Redoing delete stepped %q to folio %p, want none", op, after)
+	}
+	if got := onDisk(t, store, "groceries"); got != nil {
+		t.Errorf("Deleted folio is still on disk with notes %q", got)
+	}
+}
+
+func TestHistoryLimit(t *testing.T) {
+	store, folio := testFolio(t)
+	history := NewHistory(store, 2)
+	for _, text := range []string{"milk", "eggs", "bread"} {
+		track(t, history, "append", folio, appendNote(folio, text))
+	}
+
+	// Only the last two changes are remembered
+	for i := 0; i < 2; i++ {
+		if _, _, err := history.Undo("groceries", folio); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, _, err := history.Undo("groceries", folio); !errors.Is(err, ErrNothingToUndo) {
+		t.Errorf("Undoing past the limit got error %v, want %v", err, ErrNothingToUndo)
+	}
+	if got := summary(folio.List()); !reflect.DeepEqual(got, []string{"milk"}) {
+		t.Errorf("Folio has notes %q, want milk", got)
+	}
+}
+
+func TestForget(t *testing.T) {
+	store, folio := testFolio(t)
+	history := NewHistory(store, 20)
+	track(t, history, "append", folio, appendNote(folio, "milk"))
+
+	history.Forget("groceries")
+	if _, _, err := history.Undo("groceries", folio); !errors.Is(err, ErrNothingToUndo) {
+		t.Errorf("Undoing a forgotten change got error %v, want %v", err, ErrNothingToUndo)
+	}
+}
+
+func TestConcurrentHistory(t *testing.T) {
+	store, folio := testFolio(t)
+	history := NewHistory(store, 1000)
+
+	// Changes tracked at the same time are each remembered with the state from just before them
+	const workers, changes = 4, 20
+	wg := &sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < changes; j++ {
+				if err := history.Track("append", folio, appendNote(folio, fmt.Sprintf("%v %v", i, j))); err != nil {
+					t.Error(err)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < workers*changes; i++ {
+		if _, _, err := history.Undo("groceries", folio); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := len(folio.List()), workers*changes-i-1; got != want {
+			t.Fatalf("After undoing %v changes the folio has %v notes, want %v", i+1, got, want)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package note
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultStore keeps folios in `data/`, next to the directory 'Appened is run from
+var DefaultStore = NewStore("../data/")
+
+// Store keeps each folio as a csv file in a directory
+type Store struct {
+	Dir string // Directory the folios' csv files are kept in
+
+	// Observer, if set, is called after every write to disk with the kind of write, e.g. append,
+	// how long it took, and the error it returned
+	Observer func(op string, duration time.Duration, err error)
+}
+
+// NewStore creates a Store keeping folios in dir
+func NewStore(dir string) *Store {
+	return &Store{Dir: dir}
+}
+
+// Load reads in every folio in the store's directory
+func (s *Store) Load() (map[string]*Folio, error) {
+	// Get file names
+	files, err := os.ReadDir(s.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Init folios
+	folios := map[string]*Folio{}
+
+	// Fetch each folio, ignoring anything that is not a csv
+	for _, file := range files {
+		filename := file.Name()
+		if file.IsDir() || filepath.Ext(filename) != ".csv" {
+			continue
+		}
+		folio, err := s.parseFolioCSV(filename)
+		if err != nil {
+			return nil, err
+		}
+		folios[folio.Name] = folio
+	}
+
+	return folios, nil
+}
+
+func (s *Store) parseFolioCSV(filename string) (*Folio, error) {
+	// Open file for reading
+	filePath := filepath.Join(s.Dir, filename)
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	// Get folio's name
+	name := strings.TrimSuffix(filename, ".csv")
+
+	// Get all records as strings
+	csvReader := csv.NewReader(file)
+	records, err := csvReader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	// Create Notes from csv string records
+	notes := []Note{}
+	for i, record := range records {
+		note := Note{}
+		note.index = i
+		note.Text = record[0]
+		note.Done, err = strconv.ParseBool(record[1])
+		if err != nil {
+			return nil, err
+		}
+		note.DateCreated, err = strconv.ParseInt(record[2], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		note.DateDone, err = strconv.ParseInt(record[3], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		note.DateEdited, err = strconv.ParseInt(record[4], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		notes = append(notes, note)
+	}
+
+	// Create folio
+	folio := Folio{name, notes, filePath, &sync.RWMutex{}, s}
+
+	return &folio, nil
+}
+
+// Create creates a new folio in the store, and writes it to disk
+func (s *Store) Create(name string) (f *Folio, err error) {
+	start := time.Now()
+	defer func() { s.observe("create", start, err) }()
+
+	fileName := filepath.Join(s.Dir, name+".csv")
+	mu := &sync.RWMutex{}
+
+	file, err := os.OpenFile(fileName, os.O_CREATE|os.O_EXCL, 0666)
+	if err != nil {
+		return nil, err
+	}
+	file.Close()
+
+	f = &Folio{name, []Note{}, fileName, mu, s}
+
+	return f, nil
+}
+
+// Sync flushes every folio, and the directory listing them, to stable storage
+func (s *Store) Sync(folios map[string]*Folio) error {
+	for _, folio := range folios {
+		if err := folio.Sync(); err != nil {
+			return err
+		}
+	}
+
+	// Syncing the directory persists folios that were created or deleted
+	dir, err := os.Open(s.Dir)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+
+	return dir.Sync()
+}
+
+// observe reports a write that began at start to the store's Observer, if it has one
+func (s *Store) observe(op string, start time.Time, err error) {
+	if s != nil && s.Observer != nil {
+		s.Observer(op, time.Since(start), err)
+	}
+}
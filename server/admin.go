@@ -0,0 +1,44 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/appened/HTTPLogger"
+)
+
+// logLevelJSON is the body of GET and PUT admin/log-level
+type logLevelJSON struct {
+	Level string `json:"level"`
+}
+
+// Intialize routes for operating the server. Like the rest of the API, they require authentication.
+func (s *Server) initializeAdminRoutes() {
+	router, logger := s.router, s.logger
+
+	// GET admin/log-level Get the level the server logs at
+	router.HandleFunc("/admin/log-level", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, r, logger, http.StatusOK, logLevelJSON{logger.Level()})
+	}).Methods("GET")
+
+	// PUT admin/log-level Change the level the server logs at, without restarting it
+	router.HandleFunc("/admin/log-level", func(w http.ResponseWriter, r *http.Request) {
+		req := logLevelJSON{}
+		if err := decodeJSON(r, &req); err != nil {
+			writeProblem(w, r, logger, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		flags, err := HTTPLogger.ParseLevel(req.Level)
+		if err != nil {
+			writeProblem(w, r, logger, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		previous := logger.Level()
+		logger.SetFlags(flags)
+
+		writeJSON(w, r, logger, http.StatusOK, logLevelJSON{logger.Level()})
+		logger.WithRequest(r).Warn(fmt.Sprintf("Changed log level from %v to %v\n", previous, logger.Level()))
+	}).Methods("PUT")
+}
@@ -0,0 +1,148 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/appened/HTTPLogger"
+	"github.com/appened/audit"
+	"github.com/appened/note"
+)
+
+// OnBehalfOfHeader names who a client is acting for, e.g. the phone number that texted the Twilio client.
+// It is recorded in the audit log alongside the authenticated identity.
+const OnBehalfOfHeader = "X-On-Behalf-Of"
+
+// tracker makes a change to a folio that can be undone and is recorded in the audit log
+type tracker func(r *http.Request, op string, folio *note.Folio, change func() error) error
+
+// auditor records a change to a folio in the audit log, given its notes before and after. A nil
+// slice means the folio did not exist.
+type auditor func(r *http.Request, action string, folio string, before, after []note.Note)
+
+// track makes a change to a folio through its undo history, and records it in the audit log
+func (s *Server) track(r *http.Request, op string, folio *note.Folio, change func() error) error {
+	before := folio.List()
+	if err := s.history.Track(op, folio, change); err != nil {
+		return err
+	}
+
+	var after []note.Note
+	if op != "delete" {
+		after = folio.List()
+	}
+	s.recordAudit(r, op, folio.Name, before, after)
+	return nil
+}
+
+// recordAudit records a change to a folio in the audit log, with only the notes that changed
+func (s *Server) recordAudit(r *http.Request, action string, folio string, before, after []note.Note) {
+	changedBefore, changedAfter := changedNotes(before, after)
+	s.recordEntry(r, audit.Entry{
+		Action: action,
+		Folio:  folio,
+		Before: auditValue(s.logger, before, changedBefore),
+		After:  auditValue(s.logger, after, changedAfter),
+	})
+}
+
+// recordEntry fills in who made a request and records it in the audit log. Failing to record an
+// entry does not fail the request, as the change has already been made, but is logged as an error.
+func (s *Server) recordEntry(r *http.Request, entry audit.Entry) {
+	if identity, ok := IdentityFromContext(r.Context()); ok {
+		entry.Actor = identity.Name
+	}
+	entry.OnBehalfOf = r.Header.Get(OnBehalfOfHeader)
+	if entry.Remote = clientIPFromContext(r.Context()); entry.Remote == "" {
+		entry.Remote, _, _ = net.SplitHostPort(r.RemoteAddr)
+	}
+	entry.RequestID = HTTPLogger.RequestIDFromContext(r.Context())
+
+	if err := s.audit.Record(entry); err != nil {
+		s.logger.ApplicationError(r, fmt.Errorf("Recording audit entry: %w", err))
+	}
+}
+
+// changedNotes returns the notes that differ between before and after, by index
+func changedNotes(before, after []note.Note) ([]noteJSON, []noteJSON) {
+	changedBefore, changedAfter := []noteJSON{}, []noteJSON{}
+	for i := 0; i < len(before) || i < len(after); i++ {
+		switch {
+		case i >= len(before):
+			changedAfter = append(changedAfter, toNoteJSON(after[i]))
+		case i >= len(after):
+			changedBefore = append(changedBefore, toNoteJSON(before[i]))
+		case before[i] != after[i]:
+			changedBefore = append(changedBefore, toNoteJSON(before[i]))
+			changedAfter = append(changedAfter, toNoteJSON(after[i]))
+		}
+	}
+	return changedBefore, changedAfter
+}
+
+// auditValue encodes the changed notes of a folio for an audit entry, or nothing if the folio did not exist
+func auditValue(logger *HTTPLogger.Logger, folio []note.Note, changed []noteJSON) json.RawMessage {
+	if folio == nil {
+		return nil
+	}
+
+	value, err := json.Marshal(changed)
+	if err != nil {
+		logger.Error(err)
+		return nil
+	}
+	return value
+}
+
+// auditResponse is the body returned from GET /audit
+type auditResponse struct {
+	Entries []audit.Entry `json:"entries"`
+}
+
+// Intialize the route for querying the audit log
+func (s *Server) initializeAuditRoutes() {
+	router, logger := s.router, s.logger
+
+	// GET audit?folio=&actor=&action=&since=&until=&limit= Query the audit log, oldest first
+	router.HandleFunc("/audit", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		filter := audit.Filter{
+			Folio:  query.Get("folio"),
+			Actor:  query.Get("actor"),
+			Action: query.Get("action"),
+			Limit:  100,
+		}
+
+		var err error
+		if since := query.Get("since"); since != "" {
+			if filter.Since, err = time.Parse(time.RFC3339, since); err != nil {
+				writeProblem(w, r, logger, http.StatusBadRequest, "since must be an RFC 3339 time")
+				return
+			}
+		}
+		if until := query.Get("until"); until != "" {
+			if filter.Until, err = time.Parse(time.RFC3339, until); err != nil {
+				writeProblem(w, r, logger, http.StatusBadRequest, "until must be an RFC 3339 time")
+				return
+			}
+		}
+		if limit := query.Get("limit"); limit != "" {
+			if filter.Limit, err = strconv.Atoi(limit); err != nil || filter.Limit < 1 {
+				writeProblem(w, r, logger, http.StatusBadRequest, "limit must be a positive number")
+				return
+			}
+		}
+
+		entries, err := s.audit.Query(filter)
+		if err != nil {
+			writeServerError(w, r, logger, err)
+			return
+		}
+
+		writeJSON(w, r, logger, http.StatusOK, auditResponse{entries})
+	}).Methods("GET")
+}
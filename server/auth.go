@@ -0,0 +1,99 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// Scopes that can be granted to an identity
+const (
+	ScopeRead  = "read"  // Read folios and notes
+	ScopeWrite = "write" // Change folios and notes
+	ScopeAdmin = "admin" // Change server settings and read the audit log
+)
+
+// Identity is who made a request
+type Identity struct {
+	Name   string   // Name of the token or user that made the request
+	Scopes []string // What the identity may do, every scope if nil
+}
+
+// Can reports whether the identity has been granted scope
+func (i Identity) Can(scope string) bool {
+	if i.Scopes == nil {
+		return true
+	}
+	for _, granted := range i.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// requiredScope returns the scope needed to make a request
+func requiredScope(r *http.Request) string {
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/admin/"), r.URL.Path == "/audit":
+		return ScopeAdmin
+	case !strings.HasPrefix(r.URL.Path, "/v2/") && strings.HasSuffix(strings.TrimSuffix(r.URL.Path, "/"), "/done"):
+		// v1 toggles done with a GET
+		return ScopeWrite
+	case r.Method == http.MethodGet, r.Method == http.MethodHead, strings.HasPrefix(r.URL.Path, "/ui/"):
+		// Signing in and out of the web UI only needs read access
+		return ScopeRead
+	}
+	return ScopeWrite
+}
+
+// Authenticator decides who made a request, returning false if it could not be authenticated
+type Authenticator interface {
+	Authenticate(r *http.Request) (Identity, bool)
+}
+
+// AuthenticatorFunc allows an ordinary function to be used as an Authenticator
+type AuthenticatorFunc func(r *http.Request) (Identity, bool)
+
+// Authenticate calls f(r)
+func (f AuthenticatorFunc) Authenticate(r *http.Request) (Identity, bool) {
+	return f(r)
+}
+
+// Token is a bearer token that may be used to access the API
+type Token struct {
+	Name   string   // Name identifying who the token belongs to
+	Secret string   // Value sent in the Authorization header
+	Scopes []string // Scopes granted to the token, every scope if nil
+}
+
+// TokenAuth authenticates requests carrying one of tokens in an `Authorization: Bearer` header.
+// Tokens with an empty secret are ignored.
+func TokenAuth(tokens ...Token) Authenticator {
+	return AuthenticatorFunc(func(r *http.Request) (Identity, bool) {
+		// Get client token
+		reqToken := r.Header.Get("Authorization")
+		if !strings.HasPrefix(reqToken, "Bearer ") {
+			return Identity{}, false
+		}
+		secret := strings.TrimPrefix(reqToken, "Bearer ")
+
+		// Check if token was provided and if it is valid
+		for _, token := range tokens {
+			if token.Secret != "" && subtle.ConstantTimeCompare([]byte(secret), []byte(token.Secret)) == 1 {
+				return Identity{Name: token.Name, Scopes: token.Scopes}, true
+			}
+		}
+
+		return Identity{}, false
+	})
+}
+
+type identityKey struct{}
+
+// IdentityFromContext returns who made the request a context belongs to
+func IdentityFromContext(ctx context.Context) (Identity, bool) {
+	identity, ok := ctx.Value(identityKey{}).(Identity)
+	return identity, ok
+}
@@ -0,0 +1,153 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/appened/HTTPLogger"
+	"github.com/appened/note"
+)
+
+// batchRequest is the body of POST /batch
+type batchRequest struct {
+	Operations []batchOperation `json:"operations"`
+}
+
+// batchOperation is a single operation against a folio within a batch
+type batchOperation struct {
+	Op    string `json:"op"`              // One of append, edit, or done
+	Folio string `json:"folio"`           // Name of the folio to change
+	Index *int   `json:"index,omitempty"` // Index of the note for edit and done
+	Note  string `json:"note,omitempty"`  // Text of the note for append and edit
+}
+
+// batchResult is the outcome of a single operation, in the same position as its request
+type batchResult struct {
+	Status int    `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// batchResponse is the body returned from POST /batch
+type batchResponse struct {
+	Results []batchResult `json:"results"`
+}
+
+// batchHandler applies a list of operations across folios
+func batchHandler(logger *HTTPLogger.Logger, lookup func(name string) *note.Folio, track tracker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := batchRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, "Invalid batch: %v", err)
+			return
+		}
+		if len(req.Operations) == 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, "Batch must contain at least one operation")
+			return
+		}
+
+		results := applyBatch(r, logger.WithRequest(r), lookup, track, req.Operations)
+
+		jsonResponse, err := json.Marshal(batchResponse{results})
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			logger.ApplicationError(r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(jsonResponse)
+	}
+}
+
+// toNoteOperation validates a batch operation from a request and converts it to a note.Operation
+func toNoteOperation(op batchOperation) (note.Operation, error) {
+	kind := note.OpKind(op.Op)
+	switch kind {
+	case note.OpAppend:
+		return note.Operation{Kind: kind, Text: op.Note}, nil
+	case note.OpEdit, note.OpToggleDone:
+		if op.Index == nil {
+			return note.Operation{}, fmt.Errorf("Operation %v requires an index", op.Op)
+		}
+		return note.Operation{Kind: kind, Index: *op.Index, Text: op.Note}, nil
+	}
+
+	return note.Operation{}, fmt.Errorf("Unknown operation %q", op.Op)
+}
+
+// applyBatch applies operations across folios and returns the result of each, in the same order.
+// Operations on the same folio are applied atomically with a single write, so if one fails none
+// of the others on that folio are applied. Operations on other folios are unaffected.
+func applyBatch(r *http.Request, logger *HTTPLogger.Logger, lookup func(name string) *note.Folio, track tracker, operations []batchOperation) []batchResult {
+	results := make([]batchResult, len(operations))
+
+	// Group operations by folio, remembering each one's position in the request
+	order := []string{}
+	groups := map[string][]int{}
+	for i, op := range operations {
+		if _, ok := groups[op.Folio]; !ok {
+			order = append(order, op.Folio)
+		}
+		groups[op.Folio] = append(groups[op.Folio], i)
+	}
+
+	for _, name := range order {
+		positions := groups[name]
+
+		folio := lookup(name)
+		if folio == nil {
+			for _, i := range positions {
+				results[i] = batchResult{http.StatusNotFound, "Folio not found"}
+			}
+			continue
+		}
+
+		// Convert each request into a note operation, rejecting the folio's batch on the first invalid one
+		ops := make([]note.Operation, 0, len(positions))
+		var err error
+		for _, i := range positions {
+			var op note.Operation
+			if op, err = toNoteOperation(operations[i]); err != nil {
+				err = &note.BatchError{Op: len(ops), Err: err}
+				break
+			}
+			ops = append(ops, op)
+		}
+
+		if err == nil {
+			err = track(r, "batch", folio, func() error {
+				return folio.Batch(ops)
+			})
+		}
+
+		var batchErr *note.BatchError
+		switch {
+		case err == nil:
+			for _, i := range positions {
+				results[i].Status = http.StatusOK
+				if operations[i].Op == string(note.OpAppend) {
+					results[i].Status = http.StatusCreated
+				}
+			}
+			logger.Info(fmt.Sprintf("Applied %v operations to folio %v\n", len(positions), name))
+		case errors.As(err, &batchErr):
+			failed := positions[batchErr.Op]
+			for _, i := range positions {
+				results[i] = batchResult{http.StatusFailedDependency, fmt.Sprintf("Not applied, operation %v failed", failed)}
+			}
+			results[failed] = batchResult{http.StatusBadRequest, batchErr.Err.Error()}
+		default:
+			logger.Error(err)
+			for _, i := range positions {
+				results[i] = batchResult{http.StatusInternalServerError, "Could not write folio"}
+			}
+		}
+	}
+
+	return results
+}
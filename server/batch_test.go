@@ -0,0 +1,105 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+// createFolios creates each named folio through the v1 API
+func createFolios(t *testing.T, s *Server, names ...string) {
+	t.Helper()
+
+	for _, name := range names {
+		if w := sendForm(s, http.MethodPost, "/folios", "name="+name); w.Code != http.StatusCreated {
+			t.Fatalf("Creating folio %v got status %v: %v", name, w.Code, w.Body.String())
+		}
+	}
+}
+
+// listNotes returns the notes of a folio as the v1 API lists them, nil if it has none
+func listNotes(t *testing.T, s *Server, name string) []string {
+	t.Helper()
+
+	var notes []string
+	w := send(s, http.MethodGet, "/folios/"+name, "")
+	if err := json.Unmarshal(w.Body.Bytes(), &notes); err != nil {
+		t.Fatalf("Listing %v got %v: %v", name, w.Code, w.Body.String())
+	}
+	return notes
+}
+
+func TestBatchEndpoint(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		status    int
+		results   []batchResult
+		groceries []string
+		chores    []string
+	}{
+		{
+			name:      "across folios",
+			body:      `{"operations":[{"op":"append","folio":"groceries","note":"milk"},{"op":"append","folio":"chores","note":"sweep"},{"op":"done","folio":"groceries","index":0}]}`,
+			status:    http.StatusOK,
+			results:   []batchResult{{Status: http.StatusCreated}, {Status: http.StatusCreated}, {Status: http.StatusOK}},
+			groceries: []string{"1. milk ✅"},
+			chores:    []string{"1. sweep"},
+		},
+		{
+			name:   "failed operation rolls back only its folio",
+			body:   `{"operations":[{"op":"append","folio":"groceries","note":"milk"},{"op":"append","folio":"chores","note":"sweep"},{"op":"edit","folio":"groceries","index":5,"note":"eggs"}]}`,
+			status: http.StatusOK,
+			results: []batchResult{
+				{http.StatusFailedDependency, "Not applied, operation 2 failed"},
+				{Status: http.StatusCreated},
+				{http.StatusBadRequest, "Index too big"},
+			},
+			chores: []string{"1. sweep"},
+		},
+		{
+			name:    "missing folio",
+			body:    `{"operations":[{"op":"append","folio":"work","note":"email"},{"op":"append","folio":"chores","note":"sweep"}]}`,
+			status:  http.StatusOK,
+			results: []batchResult{{http.StatusNotFound, "Folio not found"}, {Status: http.StatusCreated}},
+			chores:  []string{"1. sweep"},
+		},
+		{
+			name:    "missing index",
+			body:    `{"operations":[{"op":"done","folio":"groceries"}]}`,
+			status:  http.StatusOK,
+			results: []batchResult{{http.StatusBadRequest, "Operation done requires an index"}},
+		},
+		{name: "no operations", body: `{"operations":[]}`, status: http.StatusBadRequest},
+		{name: "not JSON", body: `operations`, status: http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := testServer(t, Config{})
+			createFolios(t, s, "groceries", "chores")
+
+			w := send(s, http.MethodPost, "/batch", test.body)
+			if w.Code != test.status {
+				t.Fatalf("Got status %v, want %v: %v", w.Code, test.status, w.Body.String())
+			}
+			if test.status == http.StatusOK {
+				resp := batchResponse{}
+				if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(resp.Results, test.results) {
+					t.Errorf("Got results %+v, want %+v", resp.Results, test.results)
+				}
+			}
+
+			if got := listNotes(t, s, "groceries"); !reflect.DeepEqual(got, test.groceries) {
+				t.Errorf("groceries has notes %q, want %q", got, test.groceries)
+			}
+			if got := listNotes(t, s, "chores"); !reflect.DeepEqual(got, test.chores) {
+				t.Errorf("chores has notes %q, want %q", got, test.chores)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/appened/note"
+)
+
+// errFolioExists is returned when creating a folio with the name of one that already exists
+var errFolioExists = errors.New("Folio with name exists, try a different name")
+
+// folio returns the named folio, or nil if it does not exist
+func (s *Server) folio(name string) *note.Folio {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.folios[name]
+}
+
+// folioList returns every folio, in no particular order
+func (s *Server) folioList() []*note.Folio {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	list := make([]*note.Folio, 0, len(s.folios))
+	for _, folio := range s.folios {
+		list = append(list, folio)
+	}
+	return list
+}
+
+// createFolio creates an empty folio in the store and starts serving it.
+// It returns errFolioExists if there is already a folio with that name.
+func (s *Server) createFolio(name string) (*note.Folio, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.folios[name]; ok {
+		return nil, errFolioExists
+	}
+
+	folio, err := s.store.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	s.folios[name] = folio
+	s.history.Forget(name)
+
+	return folio, nil
+}
+
+// deleteFolio deletes the named folio through its undo history and stops serving it.
+// It returns the deleted folio, or nil if there was no folio with that name.
+func (s *Server) deleteFolio(r *http.Request, name string) (*note.Folio, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	folio := s.folios[name]
+	if folio == nil {
+		return nil, nil
+	}
+
+	if err := s.track(r, "delete", folio, folio.Delete); err != nil {
+		return nil, err
+	}
+	delete(s.folios, name)
+
+	return folio, nil
+}
+
+// stepHistory undoes the last change to the named folio, or redoes the last undone change if redo
+// is set. It returns the name of the change and the folio's notes before and after stepping.
+func (s *Server) stepHistory(name string, redo bool) (op string, before, after []note.Note, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	step := s.history.Undo
+	if redo {
+		step = s.history.Redo
+	}
+
+	folio := s.folios[name]
+	if folio != nil {
+		before = folio.List()
+	}
+
+	op, folio, err = step(name, folio)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	if folio == nil {
+		delete(s.folios, name)
+		return op, before, nil, nil
+	}
+	s.folios[name] = folio
+	return op, before, folio.List(), nil
+}
+
+// syncFolios flushes every folio to stable storage. Folios cannot be created or deleted until it is
+// done, so requests still in flight at shutdown cannot change the set of folios while it is written.
+func (s *Server) syncFolios() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.store.Sync(s.folios)
+}
+
+// countNotes returns the number of notes across every folio
+func (s *Server) countNotes() float64 {
+	count := 0
+	for _, folio := range s.folioList() {
+		count += len(folio.List())
+	}
+	return float64(count)
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// sendForm makes a v1 request to handler with the test token and a form body
+func sendForm(handler http.Handler, method, target, form string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(form))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return serve(handler, r)
+}
+
+func TestConcurrentFolioRequests(t *testing.T) {
+	s := testServer(t, Config{})
+	if w := sendForm(s, http.MethodPost, "/folios", "name=groceries"); w.Code != http.StatusCreated {
+		t.Fatalf("Creating folio got status %v", w.Code)
+	}
+
+	// Change folios while reading them every way the API can, for the race detector to check
+	const writers, appends = 4, 25
+	wg := &sync.WaitGroup{}
+	for i := 0; i < writers; i++ {
+		wg.Add(3)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < appends; j++ {
+				if j%2 == 0 {
+					sendForm(s, http.MethodPost, "/folios/groceries", fmt.Sprintf("note=v1+%v+%v", i, j))
+				} else {
+					send(s, http.MethodPost, "/v2/folios/groceries/notes", fmt.Sprintf(`{"text":"v2 %v %v"}`, i, j))
+				}
+			}
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < appends; j++ {
+				name := fmt.Sprintf("chores%v", i)
+				sendForm(s, http.MethodPost, "/folios", "name="+name)
+				sendForm(s, http.MethodPost, "/folios/"+name, "note=sweep")
+				send(s, http.MethodDelete, "/v2/folios/"+name, "")
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < appends; j++ {
+				for _, target := range []string{"/folios", "/folios/groceries", "/v2/folios", "/v2/folios/groceries", "/v2/folios/groceries/notes", "/metrics"} {
+					if w := send(s, http.MethodGet, target, ""); w.Code != http.StatusOK {
+						t.Errorf("GET %v got status %v", target, w.Code)
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Every append was kept
+	notes := []string{}
+	w := send(s, http.MethodGet, "/folios/groceries", "")
+	if err := json.Unmarshal(w.Body.Bytes(), &notes); err != nil {
+		t.Fatal(err)
+	}
+	if len(notes) != writers*appends {
+		t.Errorf("Folio has %v notes, want %v", len(notes), writers*appends)
+	}
+
+	folios := []string{}
+	w = send(s, http.MethodGet, "/folios", "")
+	if err := json.Unmarshal(w.Body.Bytes(), &folios); err != nil {
+		t.Fatal(err)
+	}
+	if len(folios) != 1 {
+		t.Errorf("Got folios %q, want only groceries", folios)
+	}
+}
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// statusRecorder remembers the status a handler responded with
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// routeInfo is filled in by recordRoute with the path template of the route that matched a request
+type routeInfo struct {
+	template string
+}
+
+type routeInfoKey struct{}
+
+// instrument serves a request with next, recording its route, status and latency in the server's metrics
+func (s *Server) instrument(next http.Handler, w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	recorder := &statusRecorder{w, http.StatusOK}
+	info := &routeInfo{}
+
+	next.ServeHTTP(recorder, r.WithContext(context.WithValue(r.Context(), routeInfoKey{}, info)))
+
+	s.metrics.observeRequest(info.template, r.Method, recorder.status, time.Since(start))
+}
+
+// recordRoute is router middleware that tells instrument which route matched a request
+func recordRoute(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if info, ok := r.Context().Value(routeInfoKey{}).(*routeInfo); ok {
+			info.template, _ = mux.CurrentRoute(r).GetPathTemplate()
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// readiness is the body returned from /readyz
+type readiness struct {
+	Status string            `json:"status"` // ok if every check passed
+	Checks map[string]string `json:"checks"` // Result of each check, ok or the reason it failed
+}
+
+// Initialize the health, readiness and metrics routes, which do not require authentication
+func (s *Server) initializeHealthRoutes() {
+	router, logger := s.router, s.logger
+
+	// GET healthz: The process is up and serving requests
+	router.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, r, logger, http.StatusOK, map[string]string{"status": "ok"})
+	}).Methods("GET").Name("healthz")
+
+	// GET readyz: The server can write to its data directory, and is not shutting down
+	router.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		ready := readiness{"ok", map[string]string{"storage": "ok", "shutdown": "ok"}}
+
+		if err := s.checkWritable(); err != nil {
+			ready.Checks["storage"] = err.Error()
+		}
+		if atomic.LoadInt32(&s.shuttingDown) == 1 {
+			ready.Checks["shutdown"] = "Server is shutting down"
+		}
+
+		status := http.StatusOK
+		for _, result := range ready.Checks {
+			if result != "ok" {
+				ready.Status = "unavailable"
+				status = http.StatusServiceUnavailable
+			}
+		}
+
+		writeJSON(w, r, logger, status, ready)
+	}).Methods("GET").Name("readyz")
+
+	// GET metrics: Metrics in the Prometheus text format
+	router.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		s.metrics.write(w)
+	}).Methods("GET").Name("metrics")
+}
+
+// checkWritable creates and removes a file in the store's directory
+func (s *Server) checkWritable() error {
+	file, err := os.CreateTemp(s.store.Dir, ".readyz-*")
+	if err != nil {
+		return err
+	}
+	file.Close()
+
+	return os.Remove(file.Name())
+}
@@ -0,0 +1,25 @@
+package server
+
+import (
+	"net"
+	"strings"
+)
+
+// unixPrefix marks an Addr as the path of a Unix domain socket, e.g. unix:/run/appened/appened.sock
+const unixPrefix = "unix:"
+
+// listen returns the listener Start serves on: Config.Listener if it is set, otherwise a Unix socket or TCP
+// listener for Config.Addr
+func (s *Server) listen() (net.Listener, error) {
+	if s.config.Listener != nil {
+		s.logger.Info("Listening on inherited " + s.config.Listener.Addr().String())
+		return s.config.Listener, nil
+	}
+
+	if !strings.HasPrefix(s.config.Addr, unixPrefix) {
+		s.logger.Info("Listening on " + s.config.Addr)
+		return net.Listen("tcp", s.config.Addr)
+	}
+
+	return s.listenUnix(strings.TrimPrefix(s.config.Addr, unixPrefix))
+}
@@ -0,0 +1,18 @@
+//go:build !unix
+
+package server
+
+import (
+	"errors"
+	"net"
+)
+
+// listenUnix fails, as serving on a Unix domain socket with restricted permissions needs a Unix system
+func (s *Server) listenUnix(path string) (net.Listener, error) {
+	return nil, errors.New("Unix sockets are not supported on this platform")
+}
+
+// SystemdListeners returns no sockets, as systemd socket activation needs a Unix system
+func SystemdListeners() ([]net.Listener, error) {
+	return nil, nil
+}
@@ -0,0 +1,107 @@
+//go:build unix
+
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+)
+
+// listenUnix listens on a Unix domain socket at path with permissions of Config.SocketMode
+func (s *Server) listenUnix(path string) (net.Listener, error) {
+	// Remove a socket left behind by a server that did not shut down cleanly, but nothing else
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%v exists and is not a socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+
+	// Bind the socket inside a directory only we can open, relax its permissions to SocketMode, then
+	// move it into place, so it is never briefly accessible to everyone
+	dir, err := os.MkdirTemp(filepath.Dir(path), ".appened-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	bound := filepath.Join(dir, "socket")
+	listener, err := net.Listen("unix", bound)
+	if err != nil {
+		return nil, err
+	}
+	unix := listener.(*net.UnixListener)
+	unix.SetUnlinkOnClose(false)
+
+	if err := os.Chmod(bound, s.config.SocketMode); err != nil {
+		unix.Close()
+		return nil, err
+	}
+	if err := os.Rename(bound, path); err != nil {
+		unix.Close()
+		return nil, err
+	}
+
+	s.logger.Info(fmt.Sprintf("Listening on %v with mode %v", path, s.config.SocketMode))
+	return &unixListener{unix, path}, nil
+}
+
+// unixListener removes its socket once closed. The socket was bound under another path and moved,
+// so the net package would otherwise try to remove the old one.
+type unixListener struct {
+	*net.UnixListener
+	path string
+}
+
+// Close stops listening and removes the socket
+func (l *unixListener) Close() error {
+	err := l.UnixListener.Close()
+	os.Remove(l.path)
+	return err
+}
+
+// SystemdListeners returns the sockets passed to the process by systemd socket activation, or none if
+// it was not socket activated. It unsets the environment variables systemd uses to pass them, so they
+// are not inherited by child processes.
+func SystemdListeners() ([]net.Listener, error) {
+	defer os.Unsetenv("LISTEN_PID")
+	defer os.Unsetenv("LISTEN_FDS")
+	defer os.Unsetenv("LISTEN_FDNAMES")
+
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count < 1 {
+		return nil, errors.New("LISTEN_FDS must be a positive number")
+	}
+
+	// Passed sockets start after stdin, stdout and stderr
+	const firstFD = 3
+	listeners := make([]net.Listener, 0, count)
+	for fd := firstFD; fd < firstFD+count; fd++ {
+		syscall.CloseOnExec(fd)
+
+		file := os.NewFile(uintptr(fd), "LISTEN_FD_"+strconv.Itoa(fd))
+		listener, err := net.FileListener(file)
+		file.Close()
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, fmt.Errorf("Socket %v from systemd: %w", fd, err)
+		}
+		listeners = append(listeners, listener)
+	}
+
+	return listeners, nil
+}
@@ -0,0 +1,204 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// latencyBuckets are the upper bounds, in seconds, of the latency histograms
+var latencyBuckets = []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5}
+
+// counterVec is a Prometheus counter partitioned by labels
+type counterVec struct {
+	name   string
+	help   string
+	labels []string
+	mu     *sync.Mutex
+	values map[string]float64 // Keyed by label values joined with \xff
+}
+
+func newCounterVec(name string, help string, labels ...string) *counterVec {
+	return &counterVec{name, help, labels, &sync.Mutex{}, map[string]float64{}}
+}
+
+// inc adds one to the counter for the label values, given in the same order as the counter's labels
+func (c *counterVec) inc(values ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.values[strings.Join(values, "\xff")]++
+}
+
+func (c *counterVec) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %v %v\n# TYPE %v counter\n", c.name, c.help, c.name)
+	for _, key := range sortedKeys(c.values) {
+		fmt.Fprintf(w, "%v%v %v\n", c.name, labelString(c.labels, key, ""), formatFloat(c.values[key]))
+	}
+}
+
+// histogram is the state of one set of label values in a histogramVec
+type histogram struct {
+	counts []uint64 // Observations at or below each bucket's upper bound
+	count  uint64
+	sum    float64
+}
+
+// histogramVec is a Prometheus histogram partitioned by labels
+type histogramVec struct {
+	name       string
+	help       string
+	labels     []string
+	buckets    []float64
+	mu         *sync.Mutex
+	histograms map[string]*histogram // Keyed by label values joined with \xff
+}
+
+func newHistogramVec(name string, help string, buckets []float64, labels ...string) *histogramVec {
+	return &histogramVec{name, help, labels, buckets, &sync.Mutex{}, map[string]*histogram{}}
+}
+
+// observe records a value for the label values, given in the same order as the histogram's labels
+func (h *histogramVec) observe(value float64, values ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	key := strings.Join(values, "\xff")
+	hist, ok := h.histograms[key]
+	if !ok {
+		hist = &histogram{counts: make([]uint64, len(h.buckets))}
+		h.histograms[key] = hist
+	}
+
+	for i, bound := range h.buckets {
+		if value <= bound {
+			hist.counts[i]++
+		}
+	}
+	hist.count++
+	hist.sum += value
+}
+
+func (h *histogramVec) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %v %v\n# TYPE %v histogram\n", h.name, h.help, h.name)
+	keys := make([]string, 0, len(h.histograms))
+	for key := range h.histograms {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		hist := h.histograms[key]
+		for i, bound := range h.buckets {
+			le := `le="` + formatFloat(bound) + `"`
+			fmt.Fprintf(w, "%v_bucket%v %v\n", h.name, labelString(h.labels, key, le), hist.counts[i])
+		}
+		fmt.Fprintf(w, "%v_bucket%v %v\n", h.name, labelString(h.labels, key, `le="+Inf"`), hist.count)
+		fmt.Fprintf(w, "%v_sum%v %v\n", h.name, labelString(h.labels, key, ""), formatFloat(hist.sum))
+		fmt.Fprintf(w, "%v_count%v %v\n", h.name, labelString(h.labels, key, ""), hist.count)
+	}
+}
+
+// gauge is a Prometheus gauge whose value is read when metrics are scraped
+type gauge struct {
+	name  string
+	help  string
+	value func() float64
+}
+
+func (g gauge) write(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %v %v\n# TYPE %v gauge\n%v %v\n", g.name, g.help, g.name, g.name, formatFloat(g.value()))
+}
+
+// metrics holds everything served at /metrics
+type metrics struct {
+	requests       *counterVec
+	requestLatency *histogramVec
+	storageLatency *histogramVec
+	storageErrors  *counterVec
+	rateLimited    *counterVec
+	gauges         []gauge
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		requests:       newCounterVec("appened_http_requests_total", "HTTP requests by route, method, and status.", "route", "method", "status"),
+		requestLatency: newHistogramVec("appened_http_request_duration_seconds", "Time taken to serve HTTP requests by route and method.", latencyBuckets, "route", "method"),
+		storageLatency: newHistogramVec("appened_storage_write_duration_seconds", "Time taken to write folios to disk by kind of write.", latencyBuckets, "op"),
+		storageErrors:  newCounterVec("appened_storage_errors_total", "Failed writes of folios to disk by kind of write.", "op"),
+		rateLimited:    newCounterVec("appened_rate_limited_total", "Requests rejected by rate limits by limit (ip, token, or lockout).", "limit"),
+	}
+}
+
+// observeRequest records a served request. route is the route's path template, or empty if no route matched.
+func (m *metrics) observeRequest(route string, method string, status int, duration time.Duration) {
+	if route == "" {
+		route = "unmatched"
+	}
+	m.requests.inc(route, method, strconv.Itoa(status))
+	m.requestLatency.observe(duration.Seconds(), route, method)
+}
+
+// observeStorage records a write to disk, and is used as the note.Store's Observer
+func (m *metrics) observeStorage(op string, duration time.Duration, err error) {
+	m.storageLatency.observe(duration.Seconds(), op)
+	if err != nil {
+		m.storageErrors.inc(op)
+	}
+}
+
+// write writes every metric in the Prometheus text exposition format
+func (m *metrics) write(w io.Writer) {
+	m.requests.write(w)
+	m.requestLatency.write(w)
+	m.storageLatency.write(w)
+	m.storageErrors.write(w)
+	m.rateLimited.write(w)
+	for _, g := range m.gauges {
+		g.write(w)
+	}
+}
+
+// labelString formats the label values in key as {name="value",...}, appending extra if it is set
+func labelString(names []string, key string, extra string) string {
+	pairs := []string{}
+	if len(names) > 0 {
+		for i, value := range strings.Split(key, "\xff") {
+			pairs = append(pairs, fmt.Sprintf("%v=%q", names[i], value))
+		}
+	}
+	if extra != "" {
+		pairs = append(pairs, extra)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatFloat(f float64) string {
+	if math.IsInf(f, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func sortedKeys(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
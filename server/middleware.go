@@ -0,0 +1,117 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/appened/HTTPLogger"
+	"github.com/appened/audit"
+	"github.com/gorilla/mux"
+)
+
+// publicRoutes names the routes that do not require authentication
+var publicRoutes = map[string]bool{
+	"openapi": true,
+	"healthz": true,
+	"readyz":  true,
+	"metrics": true,
+
+	"ui":          true,
+	"ui-redirect": true,
+	"ui-session":  true,
+}
+
+// Initializes Application Middleware
+func (s *Server) initailizeMiddleware() {
+	router, logger := s.router, s.logger
+
+	// Let instrument know which route served each request
+	router.Use(recordRoute)
+
+	// Rate limit by client address, except for routes anyone may access, so probes keep working
+	router.Use(func(next http.Handler) http.Handler {
+		limited := s.rateLimitIP(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isPublicRoute(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			limited.ServeHTTP(w, r)
+		})
+	})
+
+	// Authentication middleware
+	router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Skip routes that anyone may access
+			if isPublicRoute(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Authenticate user, by their token or certificate, or else the web UI's session cookie
+			ip := clientIPFromContext(r.Context())
+			identity, authorized := s.auth.Authenticate(r)
+			if !authorized {
+				var sess *session
+				if r, sess, authorized = s.sessionIdentity(r); authorized {
+					identity = sess.identity
+
+					// A cookie is sent with requests from any site, so changes must prove they came from the UI
+					if !sess.checkCSRF(r) {
+						writeProblem(w, r, logger, http.StatusForbidden, "Missing or invalid "+CSRFHeader+" header")
+						return
+					}
+				}
+			}
+			if authorized {
+				s.limits.lockouts.succeed(ip)
+				HTTPLogger.AddFields(r, "user", identity.Name)
+
+				// Reject requests the identity has not been granted the scope for
+				if scope := requiredScope(r); !identity.Can(scope) {
+					detail := fmt.Sprintf("%v does not have the %v scope", identity.Name, scope)
+					if strings.HasPrefix(r.URL.Path, "/v2/") {
+						writeProblem(w, r, logger, http.StatusForbidden, detail)
+						return
+					}
+					w.WriteHeader(http.StatusForbidden)
+					fmt.Fprint(w, detail)
+					return
+				}
+
+				ctx := context.WithValue(r.Context(), identityKey{}, identity)
+				next.ServeHTTP(w, r.WithContext(ctx))
+			} else {
+				// Reject unauthorized requests, recording the attempt and locking out addresses that keep failing
+				s.recordEntry(r, audit.Entry{Action: "auth.failure"})
+				if s.limits.lockouts.fail(ip, time.Now()) {
+					logger.WithRequest(r).Warn(fmt.Sprintf("Locked out %v after repeated failed authentications\n", ip))
+				}
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				if strings.HasPrefix(r.URL.Path, "/v2/") {
+					writeProblem(w, r, logger, http.StatusUnauthorized, "A valid bearer token is required")
+					return
+				}
+				w.WriteHeader(http.StatusUnauthorized)
+			}
+		})
+	})
+
+	// Rate limit by identity
+	router.Use(s.rateLimitToken)
+
+	// Middleware supplied by the embedding application
+	for _, middleware := range s.config.Middleware {
+		router.Use(middleware)
+	}
+}
+
+// isPublicRoute reports whether a request is for a route that does not require authentication
+func isPublicRoute(r *http.Request) bool {
+	route := mux.CurrentRoute(r)
+	return route != nil && publicRoutes[route.GetName()]
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/appened/HTTPLogger"
+	"github.com/appened/api"
+	"github.com/gorilla/mux"
+)
+
+// problem is an RFC 7807 problem details body, returned for every v2 error
+type problem struct {
+	Type     string `json:"type"`             // URI identifying the kind of problem
+	Title    string `json:"title"`            // Short summary of the kind of problem
+	Status   int    `json:"status"`           // HTTP status of the response
+	Detail   string `json:"detail,omitempty"` // Explanation specific to this occurrence
+	Instance string `json:"instance,omitempty"`
+}
+
+// writeJSON responds with v encoded as JSON
+func writeJSON(w http.ResponseWriter, r *http.Request, logger *HTTPLogger.Logger, status int, v interface{}) {
+	jsonResponse, err := json.Marshal(v)
+	if err != nil {
+		writeServerError(w, r, logger, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(jsonResponse)
+}
+
+// writeProblem responds with a problem details body describing a client error
+func writeProblem(w http.ResponseWriter, r *http.Request, logger *HTTPLogger.Logger, status int, detail string) {
+	p := problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   detail,
+		Instance: r.URL.Path,
+	}
+
+	jsonResponse, err := json.Marshal(p)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		logger.ApplicationError(r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	w.Write(jsonResponse)
+}
+
+// writeServerError logs err and responds with a problem details body that does not leak it
+func writeServerError(w http.ResponseWriter, r *http.Request, logger *HTTPLogger.Logger, err error) {
+	logger.WithRequest(r).Error(err)
+	writeProblem(w, r, logger, http.StatusInternalServerError, "")
+}
+
+// decodeJSON decodes a JSON request body into v. Bodies have already been checked against the
+// OpenAPI document by validateRequests, so a failure here means the document and handler disagree.
+func decodeJSON(r *http.Request, v interface{}) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return fmt.Errorf("Invalid JSON body: %v", err)
+	}
+
+	return nil
+}
+
+// validateRequests rejects requests that do not match the OpenAPI document before they reach a handler
+func validateRequests(validator *api.Validator, logger *HTTPLogger.Logger) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			template, err := mux.CurrentRoute(r).GetPathTemplate()
+			if err != nil {
+				writeServerError(w, r, logger, err)
+				return
+			}
+
+			err = validator.Validate(r, template, mux.Vars(r))
+			var validationErr *api.ValidationError
+			if errors.As(err, &validationErr) {
+				writeProblem(w, r, logger, validationErr.Status, validationErr.Detail)
+				return
+			}
+			if err != nil {
+				writeServerError(w, r, logger, err)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
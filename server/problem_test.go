@@ -0,0 +1,67 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestInvalidRequests(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		want   problem
+	}{
+		{
+			name:   "invalid folio name",
+			method: http.MethodPost,
+			target: "/v2/folios",
+			body:   `{"name":"my groceries"}`,
+			want:   problem{"about:blank", "Bad Request", http.StatusBadRequest, "body.name must match ^[a-zA-Z]+$", "/v2/folios"},
+		},
+		{
+			name:   "invalid index",
+			method: http.MethodGet,
+			target: "/v2/folios/groceries/notes/-1",
+			want:   problem{"about:blank", "Bad Request", http.StatusBadRequest, "path parameter index must be at least 0", "/v2/folios/groceries/notes/-1"},
+		},
+		{
+			name:   "invalid batch",
+			method: http.MethodPost,
+			target: "/v2/batch",
+			body:   `{"operations":[]}`,
+			want:   problem{"about:blank", "Bad Request", http.StatusBadRequest, "body.operations must have at least 1 items", "/v2/batch"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := testServer(t, Config{})
+			createFolios(t, s, "groceries")
+
+			w := send(s, test.method, test.target, test.body)
+			if w.Code != test.want.Status || w.Header().Get("Content-Type") != "application/problem+json" {
+				t.Fatalf("Got status %v and content type %q: %v", w.Code, w.Header().Get("Content-Type"), w.Body.String())
+			}
+			got := problem{}
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("Got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+
+	// Bodies that aren't JSON are refused before reaching a handler
+	s := testServer(t, Config{})
+	w := sendForm(s, http.MethodPost, "/v2/folios", "name=groceries")
+	if w.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("Posting a form got status %v, want %v", w.Code, http.StatusUnsupportedMediaType)
+	}
+	if folios := send(s, http.MethodGet, "/folios", ""); folios.Body.String() != "[]" {
+		t.Errorf("Refused request created folios %v", folios.Body.String())
+	}
+}
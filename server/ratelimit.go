@@ -0,0 +1,359 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/appened/HTTPLogger"
+)
+
+// Rate is a token bucket: requests are allowed at PerSecond on average, in bursts of up to Burst
+type Rate struct {
+	PerSecond float64
+	Burst     int
+}
+
+// ParseRate parses a rate written as "perSecond" or "perSecond,burst", e.g. "5,20".
+// The burst defaults to the rate rounded up.
+func ParseRate(s string) (Rate, error) {
+	perSecond, burst, hasBurst := strings.Cut(s, ",")
+
+	rate := Rate{}
+	var err error
+	if rate.PerSecond, err = strconv.ParseFloat(strings.TrimSpace(perSecond), 64); err != nil || rate.PerSecond < 0 {
+		return Rate{}, fmt.Errorf("Invalid rate %q, must be requests per second optionally followed by a burst, e.g. 5,20", s)
+	}
+
+	rate.Burst = int(math.Ceil(rate.PerSecond))
+	if hasBurst {
+		if rate.Burst, err = strconv.Atoi(strings.TrimSpace(burst)); err != nil || rate.Burst < 1 {
+			return Rate{}, fmt.Errorf("Invalid burst in rate %q, must be a positive number", s)
+		}
+	}
+
+	return rate, nil
+}
+
+// RateLimitConfig limits how often clients may make requests. Zero values disable each limit.
+type RateLimitConfig struct {
+	PerIP    Rate // Limit for each client address, applied before authentication
+	PerToken Rate // Limit for each authenticated identity
+
+	// TrustedProxies are the addresses or CIDR ranges of reverse proxies whose X-Forwarded-For
+	// header is believed when working out a client's address. Peers on a Unix socket are always trusted.
+	TrustedProxies []string
+
+	LockoutAfter int           // Lock out an address after this many failed authentications in LockoutFor
+	LockoutFor   time.Duration // How long a lockout lasts, defaults to 15 minutes
+}
+
+// bucket is the state of one client's token bucket
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// limiter keeps a token bucket per key
+type limiter struct {
+	rate    Rate
+	mu      *sync.Mutex
+	buckets map[string]*bucket
+}
+
+func newLimiter(rate Rate) *limiter {
+	return &limiter{rate, &sync.Mutex{}, map[string]*bucket{}}
+}
+
+// allow takes a token from key's bucket. If there is none it returns how long until there will be.
+func (l *limiter) allow(key string, now time.Time) (bool, time.Duration) {
+	if l.rate.PerSecond <= 0 || l.rate.Burst <= 0 {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{float64(l.rate.Burst), now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(float64(l.rate.Burst), b.tokens+now.Sub(b.last).Seconds()*l.rate.PerSecond)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+
+	return false, time.Duration((1 - b.tokens) / l.rate.PerSecond * float64(time.Second))
+}
+
+// prune forgets buckets that have refilled, as they are the same as new ones
+func (l *limiter) prune(now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate.PerSecond >= float64(l.rate.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// lockouts counts failed authentications per address, locking out those with too many
+type lockouts struct {
+	after    int
+	duration time.Duration
+	mu       *sync.Mutex
+	failures map[string][]time.Time // Recent failures per address
+	locked   map[string]time.Time   // When each locked out address may try again
+}
+
+func newLockouts(after int, duration time.Duration) *lockouts {
+	return &lockouts{after, duration, &sync.Mutex{}, map[string][]time.Time{}, map[string]time.Time{}}
+}
+
+// lockedOut returns how long until addr may try again, or 0 if it is not locked out
+func (l *lockouts) lockedOut(addr string, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if until, ok := l.locked[addr]; ok && now.Before(until) {
+		return until.Sub(now)
+	}
+	return 0
+}
+
+// fail records a failed authentication, and returns true if it locked addr out.
+// Failures from an unknown, empty, address are not counted.
+func (l *lockouts) fail(addr string, now time.Time) bool {
+	if l.after <= 0 || addr == "" {
+		return false
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	recent := []time.Time{now}
+	for _, t := range l.failures[addr] {
+		if now.Sub(t) < l.duration {
+			recent = append(recent, t)
+		}
+	}
+
+	if len(recent) >= l.after {
+		delete(l.failures, addr)
+		l.locked[addr] = now.Add(l.duration)
+		return true
+	}
+	l.failures[addr] = recent
+	return false
+}
+
+// succeed forgets the failed authentications of addr
+func (l *lockouts) succeed(addr string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.failures, addr)
+}
+
+// count returns the number of addresses currently locked out
+func (l *lockouts) count(now time.Time) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	count := 0
+	for _, until := range l.locked {
+		if now.Before(until) {
+			count++
+		}
+	}
+	return count
+}
+
+// prune forgets expired lockouts and failures
+func (l *lockouts) prune(now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for addr, until := range l.locked {
+		if !now.Before(until) {
+			delete(l.locked, addr)
+		}
+	}
+	for addr, failures := range l.failures {
+		if now.Sub(failures[0]) >= l.duration {
+			delete(l.failures, addr)
+		}
+	}
+}
+
+// rateLimits applies a server's RateLimitConfig
+type rateLimits struct {
+	perIP    *limiter
+	perToken *limiter
+	lockouts *lockouts
+	proxies  []*net.IPNet
+}
+
+func newRateLimits(config RateLimitConfig) (*rateLimits, error) {
+	if config.LockoutFor <= 0 {
+		config.LockoutFor = 15 * time.Minute
+	}
+
+	limits := &rateLimits{
+		perIP:    newLimiter(config.PerIP),
+		perToken: newLimiter(config.PerToken),
+		lockouts: newLockouts(config.LockoutAfter, config.LockoutFor),
+	}
+
+	for _, proxy := range config.TrustedProxies {
+		if !strings.Contains(proxy, "/") {
+			if strings.Contains(proxy, ":") {
+				proxy += "/128"
+			} else {
+				proxy += "/32"
+			}
+		}
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid trusted proxy: %w", err)
+		}
+		limits.proxies = append(limits.proxies, network)
+	}
+
+	return limits, nil
+}
+
+// trusted reports whether ip is a trusted proxy
+func (l *rateLimits) trusted(ip net.IP) bool {
+	for _, network := range l.proxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the address a request came from. When it came through trusted proxies, this is
+// the last address in X-Forwarded-For that is not a trusted proxy. Only a proxy on the same machine
+// can connect over a Unix socket, so peers on one are always trusted, and the address is empty if
+// such a peer doesn't say who it is forwarding for.
+func (l *rateLimits) clientIP(r *http.Request) string {
+	host := ""
+	if !unixPeer(r) {
+		var err error
+		if host, _, err = net.SplitHostPort(r.RemoteAddr); err != nil {
+			host = r.RemoteAddr
+		}
+		if ip := net.ParseIP(host); ip == nil || !l.trusted(ip) {
+			return host
+		}
+	}
+
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		hop := net.ParseIP(strings.TrimSpace(forwarded[i]))
+		if hop == nil {
+			break
+		}
+		host = hop.String()
+		if !l.trusted(hop) {
+			break
+		}
+	}
+	return host
+}
+
+// unixPeer reports whether a request was made over a Unix socket
+func unixPeer(r *http.Request) bool {
+	_, ok := r.Context().Value(http.LocalAddrContextKey).(*net.UnixAddr)
+	return ok
+}
+
+// prune forgets state that no longer limits anyone, every minute until ctx is done
+func (l *rateLimits) prune(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			l.perIP.prune(now)
+			l.perToken.prune(now)
+			l.lockouts.prune(now)
+		}
+	}
+}
+
+type clientIPKey struct{}
+
+// clientIPFromContext returns the address rateLimitIP worked out for a request
+func clientIPFromContext(ctx context.Context) string {
+	ip, _ := ctx.Value(clientIPKey{}).(string)
+	return ip
+}
+
+// rateLimitIP is middleware limiting requests per client address, and rejecting locked out addresses.
+// Requests from an unknown address are left to the per-token limit, rather than sharing one bucket.
+func (s *Server) rateLimitIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := s.limits.clientIP(r)
+		r = r.WithContext(context.WithValue(r.Context(), clientIPKey{}, ip))
+		if ip == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		now := time.Now()
+		if wait := s.limits.lockouts.lockedOut(ip, now); wait > 0 {
+			s.tooManyRequests(w, r, "lockout", wait, "Too many failed authentications, try again later")
+			return
+		}
+		if ok, wait := s.limits.perIP.allow(ip, now); !ok {
+			s.tooManyRequests(w, r, "ip", wait, "Too many requests from this address")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// rateLimitToken is middleware limiting requests per authenticated identity
+func (s *Server) rateLimitToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if identity, ok := IdentityFromContext(r.Context()); ok {
+			if ok, wait := s.limits.perToken.allow(identity.Name, time.Now()); !ok {
+				s.tooManyRequests(w, r, "token", wait, "Too many requests with this token")
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// tooManyRequests responds 429, telling the client when to retry
+func (s *Server) tooManyRequests(w http.ResponseWriter, r *http.Request, limit string, wait time.Duration, detail string) {
+	s.metrics.rateLimited.inc(limit)
+	HTTPLogger.AddFields(r, "rate_limit", limit)
+
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	if strings.HasPrefix(r.URL.Path, "/v2/") {
+		writeProblem(w, r, s.logger, http.StatusTooManyRequests, detail)
+		return
+	}
+	w.WriteHeader(http.StatusTooManyRequests)
+	fmt.Fprint(w, detail)
+}
@@ -0,0 +1,304 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// overUnixSocket makes r look like it was made over a Unix socket, as net/http serves them
+func overUnixSocket(r *http.Request) *http.Request {
+	r.RemoteAddr = "@"
+	addr := &net.UnixAddr{Name: "/run/appened/appened.sock", Net: "unix"}
+	return r.WithContext(context.WithValue(r.Context(), http.LocalAddrContextKey, addr))
+}
+
+func TestClientIP(t *testing.T) {
+	limits, err := newRateLimits(RateLimitConfig{TrustedProxies: []string{"10.0.0.1", "192.168.0.0/16", "fd00::1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		remote    string
+		unix      bool
+		forwarded []string // X-Forwarded-For headers
+		want      string
+	}{
+		{name: "direct", remote: "203.0.113.1:5000", want: "203.0.113.1"},
+		{name: "direct ignores X-Forwarded-For", remote: "203.0.113.1:5000", forwarded: []string{"198.51.100.1"}, want: "203.0.113.1"},
+		{name: "trusted proxy", remote: "10.0.0.1:5000", forwarded: []string{"198.51.100.1"}, want: "198.51.100.1"},
+		{name: "trusted proxy range", remote: "192.168.4.2:5000", forwarded: []string{"198.51.100.1"}, want: "198.51.100.1"},
+		{name: "trusted IPv6 proxy", remote: "[fd00::1]:5000", forwarded: []string{"2001:db8::1"}, want: "2001:db8::1"},
+		{name: "chain of trusted proxies", remote: "10.0.0.1:5000", forwarded: []string{"198.51.100.1, 192.168.0.7"}, want: "198.51.100.1"},
+		{name: "spoofed first hop", remote: "10.0.0.1:5000", forwarded: []string{"1.2.3.4, 198.51.100.1"}, want: "198.51.100.1"},
+		{name: "several headers", remote: "10.0.0.1:5000", forwarded: []string{"1.2.3.4", "198.51.100.1"}, want: "198.51.100.1"},
+		{name: "trusted proxy without X-Forwarded-For", remote: "10.0.0.1:5000", want: "10.0.0.1"},
+		{name: "unparsable hop", remote: "10.0.0.1:5000", forwarded: []string{"198.51.100.1, unknown"}, want: "10.0.0.1"},
+		{name: "Unix socket", unix: true, forwarded: []string{"198.51.100.1"}, want: "198.51.100.1"},
+		{name: "Unix socket through trusted proxies", unix: true, forwarded: []string{"198.51.100.1, 10.0.0.1"}, want: "198.51.100.1"},
+		{name: "Unix socket without X-Forwarded-For", unix: true, want: ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/folios", nil)
+			r.RemoteAddr = test.remote
+			if test.unix {
+				r = overUnixSocket(r)
+			}
+			for _, forwarded := range test.forwarded {
+				r.Header.Add("X-Forwarded-For", forwarded)
+			}
+
+			if got := limits.clientIP(r); got != test.want {
+				t.Errorf("Got client %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestUnixSocketClients(t *testing.T) {
+	s := testServer(t, Config{RateLimit: RateLimitConfig{
+		PerIP:        Rate{PerSecond: 0.001, Burst: 5},
+		LockoutAfter: 2,
+	}})
+
+	// get lists folios over a Unix socket, for the client forwarded for if it is set
+	get := func(forwardedFor, token string) int {
+		r := overUnixSocket(httptest.NewRequest(http.MethodGet, "/folios", nil))
+		r.Header.Set("Authorization", "Bearer "+token)
+		if forwardedFor != "" {
+			r.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		return serve(s, r).Code
+	}
+
+	// A client forwarded by a proxy on the same machine is locked out on its own
+	for i := 0; i < 2; i++ {
+		if status := get("198.51.100.1", "wrong"); status != http.StatusUnauthorized {
+			t.Fatalf("Failed authentication got status %v, want %v", status, http.StatusUnauthorized)
+		}
+	}
+	if status := get("198.51.100.1", testToken); status != http.StatusTooManyRequests {
+		t.Errorf("Locked out client got status %v, want %v", status, http.StatusTooManyRequests)
+	}
+	if status := get("198.51.100.2", testToken); status != http.StatusOK {
+		t.Errorf("Another client behind the same proxy got status %v, want %v", status, http.StatusOK)
+	}
+
+	// Clients that aren't told apart don't share a bucket or a lockout
+	for i := 0; i < 10; i++ {
+		if status := get("", "wrong"); status != http.StatusUnauthorized {
+			t.Fatalf("Failed authentication %v without X-Forwarded-For got status %v, want %v", i+1, status, http.StatusUnauthorized)
+		}
+	}
+	if status := get("", testToken); status != http.StatusOK {
+		t.Errorf("Unknown client got status %v, want %v", status, http.StatusOK)
+	}
+}
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		s    string
+		want Rate
+		err  bool
+	}{
+		{s: "5", want: Rate{5, 5}},
+		{s: "0.5", want: Rate{0.5, 1}},
+		{s: "5,20", want: Rate{5, 20}},
+		{s: " 2.5 , 3 ", want: Rate{2.5, 3}},
+		{s: "0", want: Rate{0, 0}},
+		{s: "", err: true},
+		{s: "fast", err: true},
+		{s: "-1", err: true},
+		{s: "5,0", err: true},
+		{s: "5,many", err: true},
+	}
+
+	for _, test := range tests {
+		rate, err := ParseRate(test.s)
+		if (err != nil) != test.err || rate != test.want {
+			t.Errorf("ParseRate(%q) got %+v and error %v, want %+v", test.s, rate, err, test.want)
+		}
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	l := newLimiter(Rate{PerSecond: 2, Burst: 3})
+	now := time.Now()
+
+	// A burst is allowed, then the client waits for its bucket to refill
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.allow("a", now); !ok {
+			t.Fatalf("Request %v of a burst was refused", i+1)
+		}
+	}
+	if ok, wait := l.allow("a", now); ok || wait != 500*time.Millisecond {
+		t.Errorf("Request after a burst got %v and wait %v, want refused for 500ms", ok, wait)
+	}
+	if ok, _ := l.allow("b", now); !ok {
+		t.Error("Another client was refused")
+	}
+	if ok, _ := l.allow("a", now.Add(500*time.Millisecond)); !ok {
+		t.Error("Request after waiting was refused")
+	}
+	if ok, wait := l.allow("a", now.Add(600*time.Millisecond)); ok || wait != 400*time.Millisecond {
+		t.Errorf("Request before refilling got %v and wait %v, want refused for 400ms", ok, wait)
+	}
+
+	// Only full buckets are forgotten
+	l.prune(now.Add(time.Second))
+	if _, ok := l.buckets["a"]; !ok {
+		t.Error("Pruned a bucket that was not full")
+	}
+	if _, ok := l.buckets["b"]; ok {
+		t.Error("Kept a bucket that was full")
+	}
+
+	// A zero rate allows everything
+	unlimited := newLimiter(Rate{})
+	for i := 0; i < 100; i++ {
+		if ok, _ := unlimited.allow("a", now); !ok {
+			t.Fatal("Zero rate refused a request")
+		}
+	}
+}
+
+func TestLockouts(t *testing.T) {
+	l := newLockouts(3, time.Minute)
+	now := time.Now()
+
+	// Failures only count within the lockout duration
+	l.fail("a", now)
+	l.fail("a", now.Add(10*time.Second))
+	if l.fail("a", now.Add(70*time.Second)) {
+		t.Error("Locked out after a failure that had expired")
+	}
+
+	// Succeeding forgets failures
+	l.succeed("a")
+	l.fail("a", now.Add(71*time.Second))
+	if l.fail("a", now.Add(72*time.Second)) {
+		t.Error("Locked out counting failures from before succeeding")
+	}
+
+	if !l.fail("a", now.Add(73*time.Second)) {
+		t.Fatal("Not locked out after 3 failures")
+	}
+	if wait := l.lockedOut("a", now.Add(83*time.Second)); wait != 50*time.Second {
+		t.Errorf("Locked out for %v, want 50s", wait)
+	}
+	if wait := l.lockedOut("b", now.Add(83*time.Second)); wait != 0 {
+		t.Errorf("Another address is locked out for %v", wait)
+	}
+	if count := l.count(now.Add(83 * time.Second)); count != 1 {
+		t.Errorf("Counted %v lockouts, want 1", count)
+	}
+
+	// Lockouts expire, and are then forgotten
+	if wait := l.lockedOut("a", now.Add(133*time.Second)); wait != 0 {
+		t.Errorf("Still locked out for %v after the lockout expired", wait)
+	}
+	l.fail("b", now.Add(100*time.Second))
+	l.prune(now.Add(200 * time.Second))
+	if len(l.locked) != 0 || len(l.failures) != 0 {
+		t.Errorf("Pruning kept %v lockouts and %v failures", len(l.locked), len(l.failures))
+	}
+
+	// Lockouts can be turned off
+	off := newLockouts(0, time.Minute)
+	for i := 0; i < 10; i++ {
+		if off.fail("a", now) {
+			t.Fatal("Locked out with lockouts turned off")
+		}
+	}
+}
+
+func TestRateLimitResponses(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   RateLimitConfig
+		token    string // Token of the requests before the limited one
+		requests int    // Requests made before the limited one, which must not be limited
+		remote   string // Address of the limited request
+		limit    string
+		wait     string // Retry-After of the limited request
+	}{
+		{
+			name:     "per address",
+			config:   RateLimitConfig{PerIP: Rate{PerSecond: 0.5, Burst: 2}},
+			token:    testToken,
+			requests: 2,
+			remote:   "203.0.113.1:5000",
+			limit:    "Too many requests from this address",
+			wait:     "2",
+		},
+		{
+			name:     "per token",
+			config:   RateLimitConfig{PerToken: Rate{PerSecond: 0.1, Burst: 2}},
+			token:    testToken,
+			requests: 2,
+			remote:   "203.0.113.2:5000",
+			limit:    "Too many requests with this token",
+			wait:     "10",
+		},
+		{
+			name:     "lockout",
+			config:   RateLimitConfig{LockoutAfter: 3, LockoutFor: time.Minute},
+			token:    "wrong",
+			requests: 3,
+			remote:   "203.0.113.1:5000",
+			limit:    "Too many failed authentications, try again later",
+			wait:     "60",
+		},
+	}
+
+	for _, test := range tests {
+		for _, path := range []string{"/folios", "/v2/folios"} {
+			t.Run(test.name+" "+path, func(t *testing.T) {
+				s := testServer(t, Config{RateLimit: test.config})
+
+				// request lists folios from an address
+				request := func(remote, token string) *httptest.ResponseRecorder {
+					r := httptest.NewRequest(http.MethodGet, path, nil)
+					r.RemoteAddr = remote
+					r.Header.Set("Authorization", "Bearer "+token)
+					return serve(s, r)
+				}
+
+				for i := 0; i < test.requests; i++ {
+					if w := request("203.0.113.1:5000", test.token); w.Code == http.StatusTooManyRequests {
+						t.Fatalf("Request %v was limited: %v", i+1, w.Body.String())
+					}
+				}
+
+				w := request(test.remote, testToken)
+				if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != test.wait {
+					t.Fatalf("Got status %v with Retry-After %q, want %v with %q", w.Code, w.Header().Get("Retry-After"), http.StatusTooManyRequests, test.wait)
+				}
+				if strings.HasPrefix(path, "/v2/") {
+					p := problem{}
+					if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil || p.Status != http.StatusTooManyRequests || p.Detail != test.limit {
+						t.Errorf("Got problem %+v, want detail %q", p, test.limit)
+					}
+				} else if w.Body.String() != test.limit {
+					t.Errorf("Got body %q, want %q", w.Body.String(), test.limit)
+				}
+
+				// Other addresses are not affected by a limit on one address
+				if test.remote == "203.0.113.1:5000" {
+					if w := request("203.0.113.9:5000", testToken); w.Code != http.StatusOK {
+						t.Errorf("Another address got status %v, want %v", w.Code, http.StatusOK)
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"github.com/appened/HTTPLogger"
+	"github.com/appened/api"
+	"github.com/gorilla/mux"
+)
+
+// TODO: Add surfacing a note
+
+// folioNamePattern is the set of valid folio names: one word of letters. The v1 routes are not in the OpenAPI
+// document, so they check names themselves rather than through the validation middleware.
+var folioNamePattern = regexp.MustCompile(`^[a-zA-Z]+$`)
+
+// Intialize routes
+func (s *Server) initailizeRoutes() {
+	router, logger := s.router, s.logger
+
+	// GET openapi.json: The OpenAPI document describing the API
+	router.HandleFunc("/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(api.Spec)
+	}).Methods("GET").Name("openapi")
+
+	// v2/ JSON API
+	s.initializeV2Routes(router.PathPrefix("/v2").Subrouter())
+
+	// GET folios/{name}: Get a folio's notes in an array of strings
+	router.HandleFunc("/folios/{name}{slash:/?}", func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["name"]
+		HTTPLogger.AddFields(r, "folio", name)
+
+		folio := s.folio(name)
+		if folio == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		var notes []string
+		for _, note := range folio.List() {
+			notes = append(notes, note.ListString())
+		}
+		jsonResponse, err := json.Marshal(notes)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			logger.ApplicationError(r, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(jsonResponse)
+	}).Methods("GET")
+
+	// POST folios/{name} Append a note to a folio
+	router.HandleFunc("/folios/{name}{slash:/?}", func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["name"]
+		HTTPLogger.AddFields(r, "folio", name)
+
+		if err := r.ParseForm(); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			logger.ApplicationError(r, err)
+			return
+		}
+
+		folio := s.folio(name)
+		if folio == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		err := s.track(r, "append", folio, func() error {
+			_, err := folio.Append(r.FormValue("note"))
+			return err
+		})
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			logger.ApplicationError(r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		logger.WithRequest(r).Info(fmt.Sprintf("Created note in folio %v\n", name))
+	}).Methods("POST")
+
+	// PUT folios/{name}/{index} Edit a note in a folio
+	router.HandleFunc("/folios/{name}/{index}{slash:/?}", func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["name"]
+		indexString := mux.Vars(r)["index"]
+		HTTPLogger.AddFields(r, "folio", name, "note", indexString)
+
+		index, err := strconv.Atoi(indexString)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, "Invalid note index, must be a number")
+			return
+		}
+
+		if err := r.ParseForm(); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			logger.ApplicationError(r, err)
+			return
+		}
+
+		folio := s.folio(name)
+		if folio == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		err = s.track(r, "edit", folio, func() error {
+			return folio.Edit(index, r.FormValue("note"))
+		})
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			logger.ApplicationError(r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		logger.WithRequest(r).Info(fmt.Sprintf("Edited note %v in folio %v\n", index, name))
+	}).Methods("PUT")
+
+	// GET folios/{name}/{index}/done Toggle done on note
+	router.HandleFunc("/folios/{name}/{index}/done{slash:/?}", func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["name"]
+		indexString := mux.Vars(r)["index"]
+		HTTPLogger.AddFields(r, "folio", name, "note", indexString)
+
+		index, err := strconv.Atoi(indexString)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, "Invalid note index, must be a number")
+			return
+		}
+
+		folio := s.folio(name)
+		if folio == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		err = s.track(r, "done", folio, func() error {
+			return folio.ToggleDone(index)
+		})
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, err.Error())
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		logger.WithRequest(r).Info(fmt.Sprintf("Toggled done on note %v in folio %v\n", index, name))
+	}).Methods("GET")
+
+	// POST folios/ Create a folio
+	router.HandleFunc("/folios{slash:/?}", func(w http.ResponseWriter, r *http.Request) {
+		// Get folio name from request
+		if err := r.ParseForm(); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, "Invalid form: %v", err)
+			return
+		}
+		name := r.FormValue("name")
+		HTTPLogger.AddFields(r, "folio", name)
+		logger.WithRequest(r).Debug(name)
+
+		// Validation
+		if !folioNamePattern.MatchString(name) {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, "Invalid folio name, must be one word")
+			return
+		}
+
+		// Create New Folio
+		folio, err := s.createFolio(name)
+		if errors.Is(err, errFolioExists) {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, err.Error())
+			return
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			logger.ApplicationError(r, err)
+			return
+		}
+		s.recordAudit(r, "create", name, nil, folio.List())
+
+		w.WriteHeader(http.StatusCreated)
+		logger.WithRequest(r).Info(fmt.Sprintf("Created folio named %v\n", name))
+	}).Methods("POST")
+
+	// GET folios/ List all folio names
+	router.HandleFunc("/folios{slash:/?}", func(w http.ResponseWriter, r *http.Request) {
+		folioNames := []string{}
+		for _, folio := range s.folioList() {
+			folioNames = append(folioNames, folio.Name)
+		}
+
+		jsonResponse, err := json.Marshal(folioNames)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			logger.ApplicationError(r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(jsonResponse)
+	}).Methods("GET")
+
+	// DELETE folios/{name}
+	router.HandleFunc("/folios/{name}{slash:/?}", func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["name"]
+		HTTPLogger.AddFields(r, "folio", name)
+
+		folio, err := s.deleteFolio(r, name)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			logger.ApplicationError(r, err)
+			return
+		}
+		if folio == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		logger.WithRequest(r).Info(fmt.Sprintf("Deleted folio %v\n", name))
+	}).Methods("DELETE")
+
+	// POST batch/ Apply operations across folios
+	router.HandleFunc("/batch{slash:/?}", batchHandler(logger, s.folio, s.track)).Methods("POST")
+
+	// POST folios/{name}/undo Undo the last change to a folio
+	router.HandleFunc("/folios/{name}/undo{slash:/?}", undoHandler(logger, s.stepHistory, s.recordAudit, false)).Methods("POST")
+
+	// POST folios/{name}/redo Redo the last undone change to a folio
+	router.HandleFunc("/folios/{name}/redo{slash:/?}", undoHandler(logger, s.stepHistory, s.recordAudit, true)).Methods("POST")
+
+	// Manually reset 404 middleware or it will not fire
+	router.NotFoundHandler = router.NewRoute().HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}).GetHandler()
+
+}
@@ -0,0 +1,289 @@
+// Package server serves 'Appened's REST API. A Server is an http.Handler, so it can be mounted
+// inside another Go service or tested with httptest, or run on its own with Start.
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/appened/HTTPLogger"
+	"github.com/appened/api"
+	"github.com/appened/audit"
+	"github.com/appened/note"
+	"github.com/gorilla/mux"
+)
+
+// Config configures a Server. Auth is required, everything else has a default.
+type Config struct {
+	Addr         string                            // Address Start listens on, defaults to :8081. Prefix a path with unix: for a Unix socket
+	Listener     net.Listener                      // Listener Start serves on instead of Addr, e.g. one from SystemdListeners
+	SocketMode   os.FileMode                       // Permissions of a Unix socket, defaults to 0660
+	Store        *note.Store                       // Where folios are kept, defaults to note.DefaultStore
+	Logger       *HTTPLogger.Logger                // Defaults to logging everything to stdout
+	Auth         Authenticator                     // Decides who may use the API
+	Middleware   []func(http.Handler) http.Handler // Run after authentication, in order, around every handler
+	HistoryLimit int                               // Number of changes per folio that can be undone, defaults to 20
+	AccessLog    HTTPLogger.AccessFormat           // How each request is logged once served, defaults to structured lines
+	Audit        *audit.Log                        // Where changes are recorded, defaults to audit.jsonl in the store's directory
+	RateLimit    RateLimitConfig                   // How often clients may make requests, unlimited by default
+	TLS          *TLSConfig                        // Serve over TLS rather than plain HTTP, if set
+	SessionTTL   time.Duration                     // How long a web UI session lasts after signing in, defaults to 12 hours
+
+	// ShutdownTimeout is how long Run waits for in-flight requests to finish once it is told to stop, defaults to 10s
+	ShutdownTimeout time.Duration
+}
+
+// Server serves the 'Appened API for the folios in a Store
+type Server struct {
+	config     Config
+	router     *mux.Router
+	logger     *HTTPLogger.Logger
+	auth       Authenticator
+	store      *note.Store
+	folios     map[string]*note.Folio // Guarded by mu
+	mu         *sync.RWMutex
+	history    *note.History
+	validator  *api.Validator
+	httpServer *http.Server
+	metrics    *metrics
+	audit      *audit.Log
+	limits     *rateLimits
+	certs      *certificates // Loaded when serving over TLS
+	sessions   *sessions     // Web UI users who have signed in
+	ownsAudit  bool          // Whether the audit log was opened by New, and so is closed by Shutdown
+	handler    http.Handler  // The router, wrapped in request IDs, access logging and instrumentation
+
+	shuttingDown int32 // Set to 1 once Shutdown is called, accessed atomically
+
+	ctx     context.Context    // Cancelled when the server shuts down, stopping background workers
+	cancel  context.CancelFunc // Cancels ctx
+	workers *sync.WaitGroup    // Background workers that are still running
+}
+
+// New loads the folios in the configured store and sets up the API's routes
+func New(config Config) (*Server, error) {
+	if config.Auth == nil {
+		return nil, errors.New("server: Config.Auth is required")
+	}
+	if config.Addr == "" {
+		config.Addr = ":8081"
+	}
+	if config.Store == nil {
+		config.Store = note.DefaultStore
+	}
+	if config.Logger == nil {
+		config.Logger = HTTPLogger.New(os.Stdout, HTTPLogger.LOG_ALL)
+	}
+	if config.SocketMode == 0 {
+		config.SocketMode = 0660
+	}
+	if config.HistoryLimit <= 0 {
+		config.HistoryLimit = 20
+	}
+	if config.ShutdownTimeout <= 0 {
+		config.ShutdownTimeout = 10 * time.Second
+	}
+	if config.SessionTTL <= 0 {
+		config.SessionTTL = 12 * time.Hour
+	}
+
+	s := &Server{
+		config:   config,
+		router:   mux.NewRouter(),
+		logger:   config.Logger,
+		auth:     config.Auth,
+		store:    config.Store,
+		metrics:  newMetrics(),
+		sessions: newSessions(config.SessionTTL),
+		mu:       &sync.RWMutex{},
+	}
+	s.store.Observer = s.metrics.observeStorage
+	s.metrics.gauges = []gauge{
+		{"appened_folios", "Number of folios.", func() float64 { return float64(len(s.folioList())) }},
+		{"appened_notes", "Number of notes across every folio.", s.countNotes},
+		{"appened_lockouts", "Number of client addresses locked out after failed authentications.", func() float64 {
+			return float64(s.limits.lockouts.count(time.Now()))
+		}},
+	}
+	s.httpServer = &http.Server{Addr: config.Addr, Handler: s}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	s.workers = &sync.WaitGroup{}
+
+	limits, err := newRateLimits(config.RateLimit)
+	if err != nil {
+		return nil, err
+	}
+	s.limits = limits
+
+	if config.TLS != nil {
+		if s.certs, err = newCertificates(*config.TLS, s.logger); err != nil {
+			return nil, err
+		}
+		s.httpServer.TLSConfig = s.certs.tlsConfig()
+	}
+
+	// Load Folios
+	s.logger.Info("Loading folios")
+	folios, err := s.store.Load()
+	if err != nil {
+		return nil, err
+	}
+	s.folios = folios
+	s.history = note.NewHistory(s.store, config.HistoryLimit)
+	s.logger.Info(fmt.Sprintf("Loaded %d folios\n", len(folios)))
+
+	// Open the audit log
+	s.audit = config.Audit
+	if s.audit == nil {
+		if s.audit, err = audit.Open(filepath.Join(s.store.Dir, "audit.jsonl")); err != nil {
+			return nil, err
+		}
+		s.ownsAudit = true
+		for _, repair := range s.audit.Repairs() {
+			s.logger.Warn("Audit log damaged: " + repair + ", run audit-verify to check its chain")
+		}
+	}
+
+	// Load the OpenAPI document requests are validated against
+	doc, err := api.Load()
+	if err != nil {
+		return nil, err
+	}
+	if s.validator, err = api.NewValidator(doc); err != nil {
+		return nil, err
+	}
+
+	// Add middleware
+	s.initailizeMiddleware()
+
+	// Set up routes
+	s.initializeHealthRoutes()
+	s.initializeAdminRoutes()
+	s.initializeAuditRoutes()
+	s.initializeUIRoutes()
+	s.initailizeRoutes()
+
+	accessLog := HTTPLogger.AccessLog(s.logger, config.AccessLog)
+	s.handler = HTTPLogger.RequestID(accessLog(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.instrument(s.router, w, r)
+	})))
+
+	// Forget rate limiting state that no longer limits anyone and expired sessions, and pick up renewed certificates
+	s.Go(s.limits.prune)
+	s.Go(s.sessions.prune)
+	if s.certs != nil {
+		s.Go(s.certs.watch)
+	}
+
+	return s, nil
+}
+
+// ServeHTTP serves the API, allowing a Server to be mounted in another application
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.handler.ServeHTTP(w, r)
+}
+
+// Start listens on the configured address and serves the API until Shutdown is called.
+// It returns nil once the server has been shut down.
+func (s *Server) Start() error {
+	listener, err := s.listen()
+	if err != nil {
+		return err
+	}
+
+	if s.certs != nil {
+		s.logger.Info("Serving with TLS")
+		err = s.httpServer.ServeTLS(listener, "", "")
+	} else {
+		err = s.httpServer.Serve(listener)
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+
+	return err
+}
+
+// Run starts the server and shuts it down once ctx is done, giving in-flight requests up to
+// Config.ShutdownTimeout to finish
+func (s *Server) Run(ctx context.Context) error {
+	errs := make(chan error, 1)
+	go func() {
+		errs <- s.Start()
+	}()
+
+	select {
+	case err := <-errs:
+		// The server failed to start, or was shut down by someone else
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.config.ShutdownTimeout)
+	defer cancel()
+	if err := s.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+
+	return <-errs
+}
+
+// Go runs fn in the background until the server shuts down, at which point fn's context is cancelled.
+// Shutdown waits for fn to return.
+func (s *Server) Go(fn func(ctx context.Context)) {
+	s.workers.Add(1)
+	go func() {
+		defer s.workers.Done()
+		fn(s.ctx)
+	}()
+}
+
+// Shutdown stops the server from accepting requests, waits for in-flight requests and background
+// workers to finish or for ctx to be done, then flushes every folio to stable storage
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.logger.Info("Shutting down, waiting for in-flight requests")
+	atomic.StoreInt32(&s.shuttingDown, 1)
+
+	// Stop accepting requests and drain those in flight
+	drainErr := s.httpServer.Shutdown(ctx)
+	if drainErr != nil {
+		drainErr = fmt.Errorf("Requests still in flight at shutdown: %w", drainErr)
+	}
+
+	// Stop background workers
+	s.cancel()
+	stopped := make(chan struct{})
+	go func() {
+		s.workers.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		if drainErr == nil {
+			drainErr = fmt.Errorf("Background workers still running at shutdown: %w", ctx.Err())
+		}
+	}
+
+	// Flush folios even if draining timed out, so that completed writes are not lost
+	s.logger.Info("Flushing folios to disk")
+	if err := s.syncFolios(); err != nil {
+		return err
+	}
+
+	if s.ownsAudit {
+		if err := s.audit.Close(); err != nil {
+			return err
+		}
+	}
+
+	return drainErr
+}
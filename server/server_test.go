@@ -0,0 +1,57 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/appened/HTTPLogger"
+	"github.com/appened/note"
+)
+
+// testToken is the secret of the only identity allowed to use test servers, which has every scope
+const testToken = "token"
+
+// testServer serves config from an empty store for the length of a test, filling in what it leaves out
+func testServer(t *testing.T, config Config) *Server {
+	t.Helper()
+
+	if config.Auth == nil {
+		config.Auth = TokenAuth(Token{Name: "test", Secret: testToken})
+	}
+	if config.Store == nil {
+		config.Store = note.NewStore(t.TempDir())
+	}
+	if config.Logger == nil {
+		config.Logger = HTTPLogger.New(io.Discard, HTTPLogger.LOG_ALL)
+	}
+
+	s, err := New(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Shutdown(context.Background()) })
+	return s
+}
+
+// serve sends r to handler with the test token, unless r already has an Authorization header
+func serve(handler http.Handler, r *http.Request) *httptest.ResponseRecorder {
+	if r.Header.Get("Authorization") == "" {
+		r.Header.Set("Authorization", "Bearer "+testToken)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+// send makes a request to handler with the test token, with a JSON body unless body is empty
+func send(handler http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	if body != "" {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	return serve(handler, r)
+}
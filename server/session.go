@@ -0,0 +1,130 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// SessionCookie is the cookie that holds a web UI session's ID
+	SessionCookie = "appened_session"
+	// CSRFHeader must carry the session's CSRF token on requests that change anything
+	CSRFHeader = "X-CSRF-Token"
+)
+
+// session is a signed in web UI user, identified by the secret ID in their cookie
+type session struct {
+	identity Identity
+	csrf     string // Sent back in CSRFHeader, which other sites' pages cannot set
+	expires  time.Time
+}
+
+// sessions are the web UI's signed in users. They are kept in memory, so restarting signs everyone out.
+type sessions struct {
+	mu   *sync.Mutex
+	byID map[string]*session
+	ttl  time.Duration // How long a session lasts after signing in
+}
+
+func newSessions(ttl time.Duration) *sessions {
+	return &sessions{mu: &sync.Mutex{}, byID: map[string]*session{}, ttl: ttl}
+}
+
+// create starts a session for identity, returning its ID
+func (s *sessions) create(identity Identity, now time.Time) (string, *session, error) {
+	id, err := randomToken()
+	if err != nil {
+		return "", nil, err
+	}
+	csrf, err := randomToken()
+	if err != nil {
+		return "", nil, err
+	}
+
+	sess := &session{identity: identity, csrf: csrf, expires: now.Add(s.ttl)}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.byID[id] = sess
+	return id, sess, nil
+}
+
+// get returns the session with id, if it has not expired
+func (s *sessions) get(id string, now time.Time) (*session, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sess, ok := s.byID[id]
+	if !ok || now.After(sess.expires) {
+		return nil, false
+	}
+	return sess, true
+}
+
+// delete ends the session with id
+func (s *sessions) delete(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.byID, id)
+}
+
+// prune forgets expired sessions every minute until ctx is done
+func (s *sessions) prune(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.mu.Lock()
+			for id, sess := range s.byID {
+				if now.After(sess.expires) {
+					delete(s.byID, id)
+				}
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+// fromRequest returns the session named by a request's cookie. Sessions only authenticate the v2 API
+// and the web UI's own routes, so the v1 routes that change folios on a GET cannot be forged from a link.
+func (s *sessions) fromRequest(r *http.Request) (string, *session, bool) {
+	if !strings.HasPrefix(r.URL.Path, "/v2/") && !strings.HasPrefix(r.URL.Path, "/ui/") {
+		return "", nil, false
+	}
+
+	cookie, err := r.Cookie(SessionCookie)
+	if err != nil {
+		return "", nil, false
+	}
+	sess, ok := s.get(cookie.Value, time.Now())
+	return cookie.Value, sess, ok
+}
+
+// checkCSRF reports whether a request authenticated by the session may go ahead: safe methods always may,
+// anything else must carry the session's CSRF token
+func (sess *session) checkCSRF(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	token := r.Header.Get(CSRFHeader)
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(sess.csrf)) == 1
+}
+
+// randomToken returns 32 random bytes, base64 encoded
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
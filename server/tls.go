@@ -0,0 +1,220 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/appened/HTTPLogger"
+)
+
+// TLSConfig serves the API over TLS. The certificate, key and client CA files are reloaded when they change,
+// so certificates can be renewed without restarting the server.
+type TLSConfig struct {
+	CertFile string // PEM certificate chain
+	KeyFile  string // PEM private key for the certificate
+
+	// ClientCAFile is a PEM bundle of the CAs that sign client certificates. If it is set, clients may
+	// present a certificate, which is verified against it and can be mapped to an identity with CertAuth.
+	ClientCAFile string
+
+	// RequireClientCert rejects connections without a verified client certificate
+	RequireClientCert bool
+
+	// ReloadInterval is how often the files are checked for changes, defaults to 1 minute
+	ReloadInterval time.Duration
+}
+
+// certificates holds the server's certificate and client CAs, reloading them when their files change
+type certificates struct {
+	config  TLSConfig
+	logger  *HTTPLogger.Logger
+	mu      *sync.RWMutex
+	cert    *tls.Certificate
+	roots   *x509.CertPool
+	modTime time.Time // Latest modification time of the files when they were loaded
+}
+
+func newCertificates(config TLSConfig, logger *HTTPLogger.Logger) (*certificates, error) {
+	if config.CertFile == "" || config.KeyFile == "" {
+		return nil, errors.New("server: TLSConfig requires CertFile and KeyFile")
+	}
+	if config.RequireClientCert && config.ClientCAFile == "" {
+		return nil, errors.New("server: TLSConfig.RequireClientCert requires ClientCAFile")
+	}
+	if config.ReloadInterval <= 0 {
+		config.ReloadInterval = time.Minute
+	}
+
+	c := &certificates{config: config, logger: logger, mu: &sync.RWMutex{}}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// files returns the files the certificates are loaded from
+func (c *certificates) files() []string {
+	files := []string{c.config.CertFile, c.config.KeyFile}
+	if c.config.ClientCAFile != "" {
+		files = append(files, c.config.ClientCAFile)
+	}
+	return files
+}
+
+// lastModified returns the latest modification time of the files
+func (c *certificates) lastModified() (time.Time, error) {
+	latest := time.Time{}
+	for _, file := range c.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			return latest, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// load reads the certificate, key and client CAs
+func (c *certificates) load() error {
+	modTime, err := c.lastModified()
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.config.CertFile, c.config.KeyFile)
+	if err != nil {
+		return fmt.Errorf("Loading TLS certificate: %w", err)
+	}
+
+	var roots *x509.CertPool
+	if c.config.ClientCAFile != "" {
+		pem, err := os.ReadFile(c.config.ClientCAFile)
+		if err != nil {
+			return err
+		}
+		roots = x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("No certificates found in client CA file %v", c.config.ClientCAFile)
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.cert, c.roots, c.modTime = &cert, roots, modTime
+	return nil
+}
+
+// watch reloads the files whenever they change, until ctx is done. If a reload fails, for example
+// because only one of the certificate and key has been replaced so far, the previous ones stay in use.
+func (c *certificates) watch(ctx context.Context) {
+	ticker := time.NewTicker(c.config.ReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		modTime, err := c.lastModified()
+		c.mu.RLock()
+		changed := err == nil && modTime.After(c.modTime)
+		c.mu.RUnlock()
+		if !changed {
+			continue
+		}
+
+		if err := c.load(); err != nil {
+			c.logger.Error(fmt.Errorf("Reloading TLS certificates: %w", err))
+			continue
+		}
+		c.logger.Info("Reloaded TLS certificates")
+	}
+}
+
+// tlsConfig returns a tls.Config that always uses the most recently loaded certificates
+func (c *certificates) tlsConfig() *tls.Config {
+	clientAuth := tls.NoClientCert
+	if c.config.ClientCAFile != "" {
+		clientAuth = tls.VerifyClientCertIfGiven
+	}
+	if c.config.RequireClientCert {
+		clientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	// The config for each client replaces this one, so it must offer HTTP/2 as well
+	base := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: []string{"h2", "http/1.1"},
+	}
+	base.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		c.mu.RLock()
+		defer c.mu.RUnlock()
+
+		return &tls.Config{
+			MinVersion:   base.MinVersion,
+			NextProtos:   base.NextProtos,
+			Certificates: []tls.Certificate{*c.cert},
+			ClientAuth:   clientAuth,
+			ClientCAs:    c.roots,
+		}, nil
+	}
+
+	return base
+}
+
+// ClientCert maps a client certificate to an identity. A certificate matches if its subject common name,
+// or one of its DNS or email subject alternative names, equals Subject.
+type ClientCert struct {
+	Subject string   `json:"subject"`
+	Name    string   `json:"name"`   // Name of the identity the certificate authenticates as
+	Scopes  []string `json:"scopes"` // Scopes granted to the identity, every scope if nil
+}
+
+// CertAuth authenticates requests made with a verified client certificate listed in certs.
+// It requires the server to be configured with TLSConfig.ClientCAFile.
+func CertAuth(certs ...ClientCert) Authenticator {
+	return AuthenticatorFunc(func(r *http.Request) (Identity, bool) {
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+			return Identity{}, false
+		}
+
+		leaf := r.TLS.VerifiedChains[0][0]
+		subjects := append([]string{leaf.Subject.CommonName}, leaf.DNSNames...)
+		subjects = append(subjects, leaf.EmailAddresses...)
+
+		for _, cert := range certs {
+			for _, subject := range subjects {
+				if subject != "" && subject == cert.Subject {
+					return Identity{Name: cert.Name, Scopes: cert.Scopes}, true
+				}
+			}
+		}
+
+		return Identity{}, false
+	})
+}
+
+// AnyAuth authenticates requests with the first of auths that accepts them
+func AnyAuth(auths ...Authenticator) Authenticator {
+	return AuthenticatorFunc(func(r *http.Request) (Identity, bool) {
+		for _, auth := range auths {
+			if identity, ok := auth.Authenticate(r); ok {
+				return identity, true
+			}
+		}
+		return Identity{}, false
+	})
+}
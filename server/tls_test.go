@@ -0,0 +1,233 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCert is a certificate and its key, written to PEM files
+type testCert struct {
+	cert     *x509.Certificate
+	key      *ecdsa.PrivateKey
+	certFile string
+	keyFile  string
+}
+
+// writeCert writes a certificate for name to dir, signed by ca or self-signed as a CA if ca is nil
+func writeCert(t *testing.T, dir, file, name string, ca *testCert) *testCert {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	parent, signer := template, key
+	if ca == nil {
+		template.IsCA, template.BasicConstraintsValid = true, true
+		template.KeyUsage |= x509.KeyUsageCertSign
+	} else {
+		parent, signer = ca.cert, ca.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &testCert{cert, key, filepath.Join(dir, file+".pem"), filepath.Join(dir, file+"-key.pem")}
+	writePEM(t, c.certFile, "CERTIFICATE", der)
+	writePEM(t, c.keyFile, "EC PRIVATE KEY", keyDER)
+	return c
+}
+
+// writePEM writes a single PEM block to path
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	t.Helper()
+
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// touch sets the modification time of files to later than any they had, so a reload notices the change
+func touch(t *testing.T, later time.Duration, files ...string) {
+	t.Helper()
+
+	modified := time.Now().Add(later)
+	for _, file := range files {
+		if err := os.Chtimes(file, modified, modified); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// startTLS serves config over TLS on a local port for the length of a test, returning its address
+func startTLS(t *testing.T, config Config) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Listener = listener
+	s := testServer(t, config)
+	go s.Start()
+
+	return listener.Addr().String()
+}
+
+// tlsClient trusts ca, presenting cert if it is not nil, and makes a new connection for every request
+func tlsClient(t *testing.T, ca, cert *testCert) *http.Client {
+	t.Helper()
+
+	config := &tls.Config{RootCAs: x509.NewCertPool()}
+	config.RootCAs.AddCert(ca.cert)
+	if cert != nil {
+		pair, err := tls.LoadX509KeyPair(cert.certFile, cert.keyFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		config.Certificates = []tls.Certificate{pair}
+	}
+
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: config, ForceAttemptHTTP2: true, DisableKeepAlives: true}}
+}
+
+// served returns the common name of the certificate the server at addr presents, and the HTTP version used
+func served(t *testing.T, client *http.Client, addr string) (string, string) {
+	t.Helper()
+
+	resp, err := client.Get("https://" + addr + "/healthz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.TLS.PeerCertificates[0].Subject.CommonName, resp.Proto
+}
+
+func TestTLSReloadsCertificates(t *testing.T) {
+	dir := t.TempDir()
+	ca := writeCert(t, dir, "ca", "Test CA", nil)
+	server := writeCert(t, dir, "server", "first", ca)
+
+	addr := startTLS(t, Config{TLS: &TLSConfig{CertFile: server.certFile, KeyFile: server.keyFile, ReloadInterval: 10 * time.Millisecond}})
+	client := tlsClient(t, ca, nil)
+
+	name, proto := served(t, client, addr)
+	if name != "first" {
+		t.Errorf("Server presented %q, want first", name)
+	}
+	if proto != "HTTP/2.0" {
+		t.Errorf("Served over %v, want HTTP/2.0", proto)
+	}
+
+	// waitFor polls until the server presents the certificate named want
+	waitFor := func(want string) {
+		t.Helper()
+
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			name, _ := served(t, client, addr)
+			if name == want {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("Server still presents %q, want %q", name, want)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	// A renewed certificate is picked up without restarting
+	writeCert(t, dir, "server", "second", ca)
+	touch(t, time.Second, server.certFile, server.keyFile)
+	waitFor("second")
+
+	// A certificate that fails to load leaves the previous one in use
+	if err := os.WriteFile(server.keyFile, []byte("not a key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	touch(t, 2*time.Second, server.keyFile)
+	time.Sleep(50 * time.Millisecond)
+	if name, _ := served(t, client, addr); name != "second" {
+		t.Errorf("After a failed reload the server presented %q, want second", name)
+	}
+}
+
+func TestClientCertificates(t *testing.T) {
+	dir := t.TempDir()
+	ca := writeCert(t, dir, "ca", "Test CA", nil)
+	server := writeCert(t, dir, "server", "server", ca)
+	alice := writeCert(t, dir, "alice", "alice", ca)
+	mallory := writeCert(t, dir, "mallory", "mallory", ca)
+	stranger := writeCert(t, dir, "stranger", "alice", writeCert(t, dir, "other-ca", "Other CA", nil))
+
+	addr := startTLS(t, Config{
+		Auth: CertAuth(ClientCert{Subject: "alice", Name: "alice"}),
+		TLS:  &TLSConfig{CertFile: server.certFile, KeyFile: server.keyFile, ClientCAFile: ca.certFile, RequireClientCert: true},
+	})
+
+	tests := []struct {
+		name   string
+		cert   *testCert
+		status int // 0 if the connection should be refused
+	}{
+		{"mapped certificate", alice, http.StatusOK},
+		{"unmapped certificate", mallory, http.StatusUnauthorized},
+		{"certificate from another CA", stranger, 0},
+		{"no certificate", nil, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp, err := tlsClient(t, ca, test.cert).Get("https://" + addr + "/v2/folios")
+			if test.status == 0 {
+				if err == nil {
+					resp.Body.Close()
+					t.Fatalf("Connection was accepted with status %v", resp.StatusCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != test.status {
+				t.Errorf("Got status %v, want %v", resp.StatusCode, test.status)
+			}
+		})
+	}
+}
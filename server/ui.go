@@ -0,0 +1,99 @@
+package server
+
+import (
+	"context"
+	"embed"
+	"io/fs"
+	"net/http"
+	"time"
+
+	"github.com/appened/audit"
+)
+
+// uiFiles is the web UI, a single page that uses the v2 API
+//
+//go:embed ui
+var uiFiles embed.FS
+
+// sessionJSON is the body of POST ui/login and GET ui/session
+type sessionJSON struct {
+	Name      string `json:"name"`
+	CSRFToken string `json:"csrfToken"`
+}
+
+type sessionKey struct{}
+
+// Intialize routes for the web UI. The page itself is public, and signing in exchanges a token for a session cookie.
+func (s *Server) initializeUIRoutes() {
+	router, logger, sessions := s.router, s.logger, s.sessions
+
+	// POST ui/login Start a session for whoever the request authenticated as, with a bearer token or client certificate
+	router.HandleFunc("/ui/login", func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Value(sessionKey{}).(*session); ok {
+			writeProblem(w, r, logger, http.StatusBadRequest, "Sign in with a token, not an existing session")
+			return
+		}
+
+		identity, _ := IdentityFromContext(r.Context())
+		id, sess, err := sessions.create(identity, time.Now())
+		if err != nil {
+			writeServerError(w, r, logger, err)
+			return
+		}
+
+		http.SetCookie(w, &http.Cookie{
+			Name:     SessionCookie,
+			Value:    id,
+			Path:     "/",
+			Expires:  sess.expires,
+			Secure:   r.TLS != nil,
+			HttpOnly: true,
+			SameSite: http.SameSiteStrictMode,
+		})
+		s.recordEntry(r, audit.Entry{Action: "login"})
+		writeJSON(w, r, logger, http.StatusOK, sessionJSON{identity.Name, sess.csrf})
+	}).Methods("POST")
+
+	// POST ui/logout End the request's session
+	router.HandleFunc("/ui/logout", func(w http.ResponseWriter, r *http.Request) {
+		if id, _, ok := sessions.fromRequest(r); ok {
+			sessions.delete(id)
+			s.recordEntry(r, audit.Entry{Action: "logout"})
+		}
+
+		http.SetCookie(w, &http.Cookie{Name: SessionCookie, Value: "", Path: "/", MaxAge: -1, HttpOnly: true, SameSite: http.SameSiteStrictMode})
+		w.WriteHeader(http.StatusNoContent)
+	}).Methods("POST")
+
+	// GET ui/session Who the request's session belongs to and its CSRF token, so the page can pick up where it left off
+	router.HandleFunc("/ui/session", func(w http.ResponseWriter, r *http.Request) {
+		_, sess, ok := sessions.fromRequest(r)
+		if !ok {
+			writeProblem(w, r, logger, http.StatusUnauthorized, "Not signed in")
+			return
+		}
+		writeJSON(w, r, logger, http.StatusOK, sessionJSON{sess.identity.Name, sess.csrf})
+	}).Methods("GET").Name("ui-session")
+
+	// GET ui/ The page and its scripts and styles
+	files, _ := fs.Sub(uiFiles, "ui")
+	fileServer := http.StripPrefix("/ui/", http.FileServer(http.FS(files)))
+	router.PathPrefix("/ui/").Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Security-Policy", "default-src 'self'; frame-ancestors 'none'")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Referrer-Policy", "no-referrer")
+		fileServer.ServeHTTP(w, r)
+	})).Methods("GET", "HEAD").Name("ui")
+
+	// GET ui Redirect to the page
+	router.Handle("/ui", http.RedirectHandler("/ui/", http.StatusMovedPermanently)).Methods("GET", "HEAD").Name("ui-redirect")
+}
+
+// sessionIdentity authenticates a request by its session cookie, adding the session to its context
+func (s *Server) sessionIdentity(r *http.Request) (*http.Request, *session, bool) {
+	_, sess, ok := s.sessions.fromRequest(r)
+	if !ok {
+		return r, nil, false
+	}
+	return r.WithContext(context.WithValue(r.Context(), sessionKey{}, sess)), sess, true
+}
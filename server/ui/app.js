@@ -0,0 +1,287 @@
+"use strict";
+
+// The web UI's state. csrf is sent with every change, as the session cookie alone is not enough.
+const state = {
+	csrf: "",
+	folios: [],
+	folio: "",
+	notes: [],
+	editing: -1, // Index of the note being edited, if any
+};
+
+const $ = (id) => document.getElementById(id);
+
+// api calls the v2 API, returning the decoded response. Errors are thrown with the problem's detail.
+async function api(method, path, body) {
+	const headers = { "Accept": "application/json", "X-CSRF-Token": state.csrf };
+	if (body !== undefined) {
+		headers["Content-Type"] = "application/json";
+	}
+
+	const resp = await fetch(path, {
+		method,
+		headers,
+		body: body === undefined ? undefined : JSON.stringify(body),
+		credentials: "same-origin",
+	});
+	if (resp.status === 401) {
+		showLogin();
+		throw new Error("Signed out, sign in again");
+	}
+
+	const text = await resp.text();
+	const data = text ? JSON.parse(text) : null;
+	if (!resp.ok) {
+		throw new Error((data && (data.detail || data.title)) || resp.statusText);
+	}
+	return data;
+}
+
+// run calls fn, showing any error it throws
+async function run(fn) {
+	setStatus("");
+	try {
+		await fn();
+	} catch (err) {
+		setStatus(err.message);
+	}
+}
+
+function setStatus(message) {
+	$("status").textContent = message;
+}
+
+function folioPath(name) {
+	return "/v2/folios/" + encodeURIComponent(name);
+}
+
+function showLogin() {
+	state.csrf = "";
+	$("app").hidden = true;
+	$("logout").hidden = true;
+	$("user").textContent = "";
+	$("login").hidden = false;
+	$("token").focus();
+}
+
+async function showApp(session) {
+	state.csrf = session.csrfToken;
+	$("login").hidden = true;
+	$("app").hidden = false;
+	$("logout").hidden = false;
+	$("user").textContent = session.name;
+
+	const name = decodeURIComponent(location.hash.slice(1));
+	await loadFolios();
+	if (name && state.folios.some((f) => f.name === name)) {
+		await selectFolio(name);
+	}
+}
+
+async function loadFolios() {
+	state.folios = await api("GET", "/v2/folios");
+	state.folios.sort((a, b) => a.name.localeCompare(b.name));
+	renderFolios();
+}
+
+async function selectFolio(name) {
+	state.folio = name;
+	state.editing = -1;
+	location.hash = encodeURIComponent(name);
+	await loadNotes();
+	renderFolios();
+}
+
+async function loadNotes() {
+	const folio = await api("GET", folioPath(state.folio));
+	state.notes = folio.notes || [];
+	renderNotes();
+}
+
+// refresh reloads after a change, keeping folio counts up to date
+async function refresh() {
+	await loadFolios();
+	if (state.folio) {
+		await loadNotes();
+	}
+}
+
+function renderFolios() {
+	const list = $("folios");
+	list.replaceChildren();
+	for (const folio of state.folios) {
+		const link = document.createElement("a");
+		link.href = "#" + encodeURIComponent(folio.name);
+		if (folio.name === state.folio) {
+			link.setAttribute("aria-current", "page");
+		}
+		const name = document.createElement("span");
+		name.textContent = folio.name;
+		const count = document.createElement("span");
+		count.textContent = folio.noteCount;
+		link.append(name, count);
+		link.addEventListener("click", (e) => {
+			e.preventDefault();
+			run(() => selectFolio(folio.name));
+		});
+
+		const item = document.createElement("li");
+		item.append(link);
+		list.append(item);
+	}
+}
+
+function renderNotes() {
+	$("folio").hidden = !state.folio;
+	$("folio-title").textContent = state.folio;
+
+	const list = $("notes");
+	list.replaceChildren();
+	for (const note of state.notes) {
+		if (note.done && !$("show-done").checked) {
+			continue;
+		}
+
+		const item = document.createElement("li");
+		item.value = note.index + 1;
+		item.classList.toggle("done", note.done);
+		const row = document.createElement("div");
+		item.append(row);
+
+		const done = document.createElement("input");
+		done.type = "checkbox";
+		done.checked = note.done;
+		done.title = "Done";
+		done.addEventListener("change", () => run(async () => {
+			await api("POST", folioPath(state.folio) + "/notes/" + note.index + "/done", { done: done.checked });
+			await refresh();
+		}));
+		row.append(done);
+
+		if (state.editing === note.index) {
+			row.append(editForm(note));
+		} else {
+			const text = document.createElement("span");
+			text.className = "text";
+			text.textContent = note.text;
+			text.addEventListener("dblclick", () => edit(note.index));
+
+			const button = document.createElement("button");
+			button.type = "button";
+			button.textContent = "Edit";
+			button.addEventListener("click", () => edit(note.index));
+			row.append(text, button);
+		}
+
+		list.append(item);
+	}
+}
+
+function edit(index) {
+	state.editing = index;
+	renderNotes();
+}
+
+// editForm replaces a note's text while it is edited. Enter saves, Escape cancels.
+function editForm(note) {
+	const form = document.createElement("form");
+	form.className = "text";
+	const input = document.createElement("input");
+	input.value = note.text;
+	input.required = true;
+	const save = document.createElement("button");
+	save.type = "submit";
+	save.textContent = "Save";
+	form.append(input, save);
+
+	form.addEventListener("submit", (e) => {
+		e.preventDefault();
+		run(async () => {
+			if (input.value !== note.text) {
+				await api("PATCH", folioPath(state.folio) + "/notes/" + note.index, { text: input.value });
+			}
+			state.editing = -1;
+			await refresh();
+		});
+	});
+	input.addEventListener("keydown", (e) => {
+		if (e.key === "Escape") {
+			edit(-1);
+		}
+	});
+	setTimeout(() => input.focus());
+	return form;
+}
+
+$("login").addEventListener("submit", (e) => {
+	e.preventDefault();
+	run(async () => {
+		const resp = await fetch("/ui/login", {
+			method: "POST",
+			headers: { "Authorization": "Bearer " + $("token").value },
+			credentials: "same-origin",
+		});
+		if (!resp.ok) {
+			throw new Error(resp.status === 401 ? "Invalid token" : "Signing in failed: " + resp.statusText);
+		}
+		$("token").value = "";
+		await showApp(await resp.json());
+	});
+});
+
+$("logout").addEventListener("click", () => run(async () => {
+	await api("POST", "/ui/logout");
+	state.folio = "";
+	location.hash = "";
+	showLogin();
+}));
+
+$("create-folio").addEventListener("submit", (e) => {
+	e.preventDefault();
+	run(async () => {
+		const name = $("folio-name").value;
+		await api("POST", "/v2/folios", { name });
+		$("folio-name").value = "";
+		await loadFolios();
+		await selectFolio(name);
+	});
+});
+
+$("append").addEventListener("submit", (e) => {
+	e.preventDefault();
+	run(async () => {
+		await api("POST", folioPath(state.folio) + "/notes", { text: $("note-text").value });
+		$("note-text").value = "";
+		await refresh();
+	});
+});
+
+$("show-done").addEventListener("change", renderNotes);
+
+$("undo").addEventListener("click", () => run(async () => {
+	const result = await api("POST", folioPath(state.folio) + "/undo");
+	await refresh();
+	setStatus("Undid " + result.undone);
+}));
+
+$("redo").addEventListener("click", () => run(async () => {
+	const result = await api("POST", folioPath(state.folio) + "/redo");
+	await refresh();
+	setStatus("Redid " + result.redone);
+}));
+
+// Pick up changes made elsewhere, unless a note is being edited
+setInterval(() => {
+	if (state.csrf && state.editing < 0 && !document.hidden) {
+		refresh().catch((err) => setStatus(err.message));
+	}
+}, 10000);
+
+// Carry on with an existing session, or ask for a token
+fetch("/ui/session", { credentials: "same-origin" }).then(async (resp) => {
+	if (resp.ok) {
+		await run(async () => showApp(await resp.json()));
+	} else {
+		showLogin();
+	}
+});
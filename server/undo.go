@@ -0,0 +1,64 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/appened/HTTPLogger"
+	"github.com/appened/note"
+	"github.com/gorilla/mux"
+)
+
+// undoResponse is the body returned from the undo and redo routes
+type undoResponse struct {
+	Undone string `json:"undone,omitempty"` // Name of the change that was undone
+	Redone string `json:"redone,omitempty"` // Name of the change that was redone
+}
+
+// stepper undoes the last change to a folio, or redoes the last undone change if redo is set,
+// returning the name of the change and the folio's notes before and after it
+type stepper func(name string, redo bool) (op string, before, after []note.Note, err error)
+
+// undoHandler undoes the last change to a folio, or redoes the last undone change if redo is set
+func undoHandler(logger *HTTPLogger.Logger, step stepper, record auditor, redo bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["name"]
+		HTTPLogger.AddFields(r, "folio", name)
+
+		action, change := "Undid", "undo"
+		if redo {
+			action, change = "Redid", "redo"
+		}
+
+		op, before, after, err := step(name, redo)
+		if errors.Is(err, note.ErrNothingToUndo) || errors.Is(err, note.ErrNothingToRedo) {
+			w.WriteHeader(http.StatusConflict)
+			fmt.Fprint(w, err.Error())
+			return
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			logger.ApplicationError(r, err)
+			return
+		}
+		record(r, change, name, before, after)
+
+		resp := undoResponse{Undone: op}
+		if redo {
+			resp = undoResponse{Redone: op}
+		}
+		jsonResponse, err := json.Marshal(resp)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			logger.ApplicationError(r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(jsonResponse)
+		logger.WithRequest(r).Info(fmt.Sprintf("%v %v in folio %v\n", action, op, name))
+	}
+}
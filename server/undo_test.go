@@ -0,0 +1,52 @@
+package server
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUndoRoutes(t *testing.T) {
+	s := testServer(t, Config{})
+	createFolios(t, s, "groceries")
+	sendForm(s, http.MethodPost, "/folios/groceries", "note=milk")
+	sendForm(s, http.MethodPost, "/folios/groceries", "note=eggs")
+
+	steps := []struct {
+		method string
+		target string
+		status int
+		body   string   // Start of the response body
+		notes  []string // Notes of groceries afterwards, nil if it doesn't exist
+		exists bool
+	}{
+		{http.MethodPost, "/folios/groceries/undo", http.StatusOK, `{"undone":"append"}`, []string{"1. milk"}, true},
+		{http.MethodPost, "/folios/groceries/redo", http.StatusOK, `{"redone":"append"}`, []string{"1. milk", "2. eggs"}, true},
+		{http.MethodPost, "/folios/groceries/redo", http.StatusConflict, "Nothing to redo", []string{"1. milk", "2. eggs"}, true},
+		{http.MethodDelete, "/folios/groceries", http.StatusOK, "", nil, false},
+		{http.MethodPost, "/folios/groceries/undo", http.StatusOK, `{"undone":"delete"}`, []string{"1. milk", "2. eggs"}, true},
+		{http.MethodPost, "/v2/folios/groceries/redo", http.StatusOK, `{"redone":"delete"}`, nil, false},
+		{http.MethodPost, "/v2/folios/groceries/undo", http.StatusOK, `{"undone":"delete"}`, []string{"1. milk", "2. eggs"}, true},
+		{http.MethodPost, "/folios/groceries/undo", http.StatusOK, `{"undone":"append"}`, []string{"1. milk"}, true},
+		{http.MethodPost, "/folios/groceries/undo", http.StatusOK, `{"undone":"append"}`, nil, true},
+		{http.MethodPost, "/folios/groceries/undo", http.StatusConflict, "Nothing to undo", nil, true},
+	}
+
+	for i, step := range steps {
+		w := send(s, step.method, step.target, "")
+		if w.Code != step.status || !strings.HasPrefix(w.Body.String(), step.body) {
+			t.Fatalf("Step %v: %v %v got %v %q, want %v %q", i+1, step.method, step.target, w.Code, w.Body.String(), step.status, step.body)
+		}
+
+		get := send(s, http.MethodGet, "/folios/groceries", "")
+		if exists := get.Code == http.StatusOK; exists != step.exists {
+			t.Fatalf("Step %v: getting groceries got status %v", i+1, get.Code)
+		}
+		if step.exists {
+			if got := listNotes(t, s, "groceries"); !reflect.DeepEqual(got, step.notes) {
+				t.Errorf("Step %v: groceries has notes %q, want %q", i+1, got, step.notes)
+			}
+		}
+	}
+}
@@ -0,0 +1,351 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/appened/HTTPLogger"
+	"github.com/appened/note"
+	"github.com/gorilla/mux"
+)
+
+// noteJSON is the v2 representation of a note
+type noteJSON struct {
+	Index       int    `json:"index"`
+	Text        string `json:"text"`
+	Done        bool   `json:"done"`
+	DateCreated int64  `json:"dateCreated"`
+	DateDone    int64  `json:"dateDone"`
+	DateEdited  int64  `json:"dateEdited"`
+}
+
+// folioJSON is the v2 representation of a folio
+type folioJSON struct {
+	Name  string     `json:"name"`
+	Notes []noteJSON `json:"notes"`
+}
+
+// folioSummaryJSON is the v2 representation of a folio when listing folios
+type folioSummaryJSON struct {
+	Name      string `json:"name"`
+	NoteCount int    `json:"noteCount"`
+}
+
+func toNoteJSON(n note.Note) noteJSON {
+	return noteJSON{n.Index(), n.Text, n.Done, n.DateCreated, n.DateDone, n.DateEdited}
+}
+
+// toNotesJSON converts notes, keeping only those matching done if it is set
+func toNotesJSON(notes []note.Note, done *bool) []noteJSON {
+	result := []noteJSON{}
+	for _, n := range notes {
+		if done == nil || n.Done == *done {
+			result = append(result, toNoteJSON(n))
+		}
+	}
+	return result
+}
+
+// Intialize the v2 routes, which take and return JSON and report errors as RFC 7807 problem details.
+// Requests are validated against the OpenAPI document before they reach a handler.
+func (s *Server) initializeV2Routes(router *mux.Router) {
+	logger := s.logger
+
+	router.Use(validateRequests(s.validator, logger))
+
+	// GET v2/folios List all folios
+	router.HandleFunc("/folios", func(w http.ResponseWriter, r *http.Request) {
+		summaries := []folioSummaryJSON{}
+		for _, folio := range s.folioList() {
+			summaries = append(summaries, folioSummaryJSON{folio.Name, len(folio.List())})
+		}
+
+		writeJSON(w, r, logger, http.StatusOK, summaries)
+	}).Methods("GET")
+
+	// POST v2/folios Create a folio
+	router.HandleFunc("/folios", func(w http.ResponseWriter, r *http.Request) {
+		req := struct {
+			Name string `json:"name"`
+		}{}
+		if err := decodeJSON(r, &req); err != nil {
+			writeProblem(w, r, logger, http.StatusBadRequest, err.Error())
+			return
+		}
+		HTTPLogger.AddFields(r, "folio", req.Name)
+
+		folio, err := s.createFolio(req.Name)
+		if errors.Is(err, errFolioExists) {
+			writeProblem(w, r, logger, http.StatusConflict, err.Error())
+			return
+		}
+		if err != nil {
+			writeServerError(w, r, logger, err)
+			return
+		}
+		s.recordAudit(r, "create", req.Name, nil, folio.List())
+
+		w.Header().Set("Location", "/v2/folios/"+folio.Name)
+		writeJSON(w, r, logger, http.StatusCreated, folioJSON{folio.Name, []noteJSON{}})
+		logger.WithRequest(r).Info(fmt.Sprintf("Created folio named %v\n", folio.Name))
+	}).Methods("POST")
+
+	// GET v2/folios/{name} Get a folio and its notes, optionally filtered with ?done=
+	router.HandleFunc("/folios/{name}", func(w http.ResponseWriter, r *http.Request) {
+		folio := v2Folio(w, r, logger, s.folio)
+		if folio == nil {
+			return
+		}
+
+		done := v2DoneFilter(r)
+
+		writeJSON(w, r, logger, http.StatusOK, folioJSON{folio.Name, toNotesJSON(folio.List(), done)})
+	}).Methods("GET")
+
+	// DELETE v2/folios/{name} Delete a folio
+	router.HandleFunc("/folios/{name}", func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["name"]
+		HTTPLogger.AddFields(r, "folio", name)
+
+		folio, err := s.deleteFolio(r, name)
+		if err != nil {
+			writeServerError(w, r, logger, err)
+			return
+		}
+		if folio == nil {
+			writeProblem(w, r, logger, http.StatusNotFound, fmt.Sprintf("Folio %v does not exist", name))
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+		logger.WithRequest(r).Info(fmt.Sprintf("Deleted folio %v\n", folio.Name))
+	}).Methods("DELETE")
+
+	// GET v2/folios/{name}/notes List a folio's notes, optionally filtered with ?done=
+	router.HandleFunc("/folios/{name}/notes", func(w http.ResponseWriter, r *http.Request) {
+		folio := v2Folio(w, r, logger, s.folio)
+		if folio == nil {
+			return
+		}
+
+		done := v2DoneFilter(r)
+
+		writeJSON(w, r, logger, http.StatusOK, toNotesJSON(folio.List(), done))
+	}).Methods("GET")
+
+	// POST v2/folios/{name}/notes Append a note to a folio
+	router.HandleFunc("/folios/{name}/notes", func(w http.ResponseWriter, r *http.Request) {
+		folio := v2Folio(w, r, logger, s.folio)
+		if folio == nil {
+			return
+		}
+
+		req := struct {
+			Text string `json:"text"`
+		}{}
+		if err := decodeJSON(r, &req); err != nil {
+			writeProblem(w, r, logger, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		var created note.Note
+		err := s.track(r, "append", folio, func() error {
+			var err error
+			created, err = folio.Append(req.Text)
+			return err
+		})
+		if err != nil {
+			writeServerError(w, r, logger, err)
+			return
+		}
+
+		w.Header().Set("Location", fmt.Sprintf("/v2/folios/%v/notes/%v", folio.Name, created.Index()))
+		writeJSON(w, r, logger, http.StatusCreated, toNoteJSON(created))
+		logger.WithRequest(r).Info(fmt.Sprintf("Created note in folio %v\n", folio.Name))
+	}).Methods("POST")
+
+	// GET v2/folios/{name}/notes/{index} Get a single note
+	router.HandleFunc("/folios/{name}/notes/{index}", func(w http.ResponseWriter, r *http.Request) {
+		folio := v2Folio(w, r, logger, s.folio)
+		if folio == nil {
+			return
+		}
+
+		n, ok := v2Note(w, r, logger, folio)
+		if !ok {
+			return
+		}
+
+		writeJSON(w, r, logger, http.StatusOK, toNoteJSON(n))
+	}).Methods("GET")
+
+	// PATCH v2/folios/{name}/notes/{index} Edit the text of a note
+	router.HandleFunc("/folios/{name}/notes/{index}", func(w http.ResponseWriter, r *http.Request) {
+		folio := v2Folio(w, r, logger, s.folio)
+		if folio == nil {
+			return
+		}
+
+		n, ok := v2Note(w, r, logger, folio)
+		if !ok {
+			return
+		}
+
+		req := struct {
+			Text string `json:"text"`
+		}{}
+		if err := decodeJSON(r, &req); err != nil {
+			writeProblem(w, r, logger, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		err := s.track(r, "edit", folio, func() error {
+			if err := folio.Edit(n.Index(), req.Text); err != nil {
+				return err
+			}
+			n, _ = folio.Note(n.Index())
+			return nil
+		})
+		if err != nil {
+			writeServerError(w, r, logger, err)
+			return
+		}
+
+		writeJSON(w, r, logger, http.StatusOK, toNoteJSON(n))
+		logger.WithRequest(r).Info(fmt.Sprintf("Edited note %v in folio %v\n", n.Index(), folio.Name))
+	}).Methods("PATCH")
+
+	// POST v2/folios/{name}/notes/{index}/done Mark a note as done or not done
+	router.HandleFunc("/folios/{name}/notes/{index}/done", func(w http.ResponseWriter, r *http.Request) {
+		folio := v2Folio(w, r, logger, s.folio)
+		if folio == nil {
+			return
+		}
+
+		n, ok := v2Note(w, r, logger, folio)
+		if !ok {
+			return
+		}
+
+		req := struct {
+			Done bool `json:"done"`
+		}{}
+		if err := decodeJSON(r, &req); err != nil {
+			writeProblem(w, r, logger, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		err := s.track(r, "done", folio, func() error {
+			if err := folio.SetDone(n.Index(), req.Done); err != nil {
+				return err
+			}
+			n, _ = folio.Note(n.Index())
+			return nil
+		})
+		if err != nil {
+			writeServerError(w, r, logger, err)
+			return
+		}
+
+		writeJSON(w, r, logger, http.StatusOK, toNoteJSON(n))
+		logger.WithRequest(r).Info(fmt.Sprintf("Set done to %v on note %v in folio %v\n", n.Done, n.Index(), folio.Name))
+	}).Methods("POST")
+
+	// POST v2/folios/{name}/undo Undo the last change to a folio
+	router.HandleFunc("/folios/{name}/undo", v2UndoHandler(logger, s.stepHistory, s.recordAudit, false)).Methods("POST")
+
+	// POST v2/folios/{name}/redo Redo the last undone change to a folio
+	router.HandleFunc("/folios/{name}/redo", v2UndoHandler(logger, s.stepHistory, s.recordAudit, true)).Methods("POST")
+
+	// POST v2/batch Apply operations across folios
+	router.HandleFunc("/batch", func(w http.ResponseWriter, r *http.Request) {
+		req := batchRequest{}
+		if err := decodeJSON(r, &req); err != nil {
+			writeProblem(w, r, logger, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeJSON(w, r, logger, http.StatusOK, batchResponse{applyBatch(r, logger.WithRequest(r), s.folio, s.track, req.Operations)})
+	}).Methods("POST")
+
+	router.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeProblem(w, r, logger, http.StatusNotFound, "")
+	})
+	router.MethodNotAllowedHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeProblem(w, r, logger, http.StatusMethodNotAllowed, "")
+	})
+}
+
+// v2Folio returns the folio named in the route, or responds with 404 and returns nil if it does not exist
+func v2Folio(w http.ResponseWriter, r *http.Request, logger *HTTPLogger.Logger, lookup func(name string) *note.Folio) *note.Folio {
+	name := mux.Vars(r)["name"]
+	HTTPLogger.AddFields(r, "folio", name)
+
+	folio := lookup(name)
+	if folio == nil {
+		writeProblem(w, r, logger, http.StatusNotFound, fmt.Sprintf("Folio %v does not exist", name))
+	}
+
+	return folio
+}
+
+// v2Note returns the note whose index is in the route, or responds with 404 if there is no such note
+func v2Note(w http.ResponseWriter, r *http.Request, logger *HTTPLogger.Logger, folio *note.Folio) (note.Note, bool) {
+	indexString := mux.Vars(r)["index"]
+	HTTPLogger.AddFields(r, "note", indexString)
+
+	index, err := strconv.Atoi(indexString)
+	if err == nil {
+		var n note.Note
+		if n, err = folio.Note(index); err == nil {
+			return n, true
+		}
+	}
+
+	writeProblem(w, r, logger, http.StatusNotFound, fmt.Sprintf("Note %v does not exist in folio %v", indexString, folio.Name))
+	return note.Note{}, false
+}
+
+// v2DoneFilter returns the value of the optional done query parameter
+func v2DoneFilter(r *http.Request) *bool {
+	value := r.URL.Query().Get("done")
+	if value == "" {
+		return nil
+	}
+
+	done, _ := strconv.ParseBool(value)
+	return &done
+}
+
+// v2UndoHandler undoes the last change to a folio, or redoes the last undone change if redo is set
+func v2UndoHandler(logger *HTTPLogger.Logger, step stepper, record auditor, redo bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["name"]
+
+		action, change := "Undid", "undo"
+		if redo {
+			action, change = "Redid", "redo"
+		}
+
+		HTTPLogger.AddFields(r, "folio", name)
+
+		op, before, after, err := step(name, redo)
+		if errors.Is(err, note.ErrNothingToUndo) || errors.Is(err, note.ErrNothingToRedo) {
+			writeProblem(w, r, logger, http.StatusConflict, err.Error())
+			return
+		}
+		if err != nil {
+			writeServerError(w, r, logger, err)
+			return
+		}
+		record(r, change, name, before, after)
+
+		resp := undoResponse{Undone: op}
+		if redo {
+			resp = undoResponse{Redone: op}
+		}
+		writeJSON(w, r, logger, http.StatusOK, resp)
+		logger.WithRequest(r).Info(fmt.Sprintf("%v %v in folio %v\n", action, op, name))
+	}
+}